@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"time"
 
+	"go-jwt/common/jwt"
+	"go-jwt/modules/auth"
+	"go-jwt/modules/client"
 	"go-jwt/modules/role"
 	"go-jwt/modules/user"
 	"log"
@@ -64,9 +67,84 @@ func GetDB() *gorm.DB {
 }
 
 func migrateDatabase(db *gorm.DB) error {
-	err := db.AutoMigrate(&user.User{}, &role.Role{})
+	err := db.AutoMigrate(&user.User{}, &user.PasswordHistory{}, &role.Role{}, &role.Permission{}, &auth.RefreshToken{}, &auth.AuthorizationCode{}, &auth.LoginFailure{}, &auth.TOTPCredential{}, &auth.RecoveryCode{}, &auth.MFAChallenge{}, &auth.PasswordResetToken{}, &auth.EmailVerificationToken{}, &auth.MagicLink{}, &auth.WebAuthnCredential{}, &auth.WebAuthnChallenge{}, &client.Client{}, &jwt.RevokedToken{}, &jwt.UserTokenRevocation{}, &jwt.Session{})
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := migrateUserRoles(db); err != nil {
+		return err
+	}
+	return seedPermissions(db)
+}
+
+// migrateUserRoles moves the single users.role_id column of older schemas
+// into the user_roles join table and drops the column.
+func migrateUserRoles(db *gorm.DB) error {
+
+	if !db.Migrator().HasColumn(&user.User{}, "role_id") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("INSERT INTO user_roles (user_id, role_id) SELECT id, role_id FROM users WHERE role_id IS NOT NULL ON CONFLICT DO NOTHING").Error
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&user.User{}, "role_id")
+	})
+}
+
+// seedPermissions creates the permissions guarding the API and grants the ones
+// it creates to the role named by ADMIN_ROLE (default "admin") when it exists,
+// so a fresh installation is not locked out. A permission revoked from that
+// role later is not granted again on the next start, unless the role has no
+// permissions left at all.
+func seedPermissions(db *gorm.DB) error {
+
+	var all, created []role.Permission
+	for _, permission := range role.DefaultPermissions {
+
+		var existing []role.Permission
+		if err := db.Where(role.Permission{Name: permission.Name}).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) != 0 {
+			all = append(all, existing[0])
+			continue
+		}
+
+		permission.Version = time.Now().UnixMilli()
+		if err := db.Create(&permission).Error; err != nil {
+			return err
+		}
+		all = append(all, permission)
+		created = append(created, permission)
+	}
+
+	adminRole := os.Getenv("ADMIN_ROLE")
+	if adminRole == "" {
+		adminRole = "admin"
+	}
+
+	var admin []role.Role
+	if err := db.Where(&role.Role{Name: adminRole}).Limit(1).Find(&admin).Error; err != nil {
+		return err
+	}
+	if len(admin) == 0 {
+		log.Printf("Role %s not found, skipping permission grant", adminRole)
+		return nil
+	}
+
+	association := db.Model(&admin[0]).Association("Permissions")
+	granted := association.Count()
+	if association.Error != nil {
+		return association.Error
+	}
+	if granted == 0 {
+		created = all
+	}
+	if len(created) == 0 {
+		return nil
+	}
+	return association.Append(&created)
 }
@@ -0,0 +1,74 @@
+package jwt
+
+import (
+	"crypto"
+	_ "crypto/sha512"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// IDTokenClaims are the claims of an OpenID Connect ID token. The audience is
+// the client the user signed in to, so ID tokens are never accepted as access
+// tokens.
+type IDTokenClaims struct {
+	Nonce           string           `json:"nonce,omitempty"`
+	AuthTime        *jwt.NumericDate `json:"auth_time,omitempty"`
+	AccessTokenHash string           `json:"at_hash,omitempty"`
+	AuthorizedParty string           `json:"azp,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateIDToken signs an ID token for subject issued to clientID. The
+// at_hash claim binds it to accessToken when one is given.
+func GenerateIDToken(subject, clientID, nonce string, authTime time.Time, accessToken string) (string, error) {
+
+	signingKey := keyring.Active()
+	if !signingKey.CanSign() {
+		return "", errors.New("no private key configured for signing tokens")
+	}
+
+	now := time.Now()
+
+	claims := &IDTokenClaims{
+		Nonce:           nonce,
+		AuthTime:        jwt.NewNumericDate(authTime),
+		AuthorizedParty: clientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    policy.Issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(policy.DefaultTTL)),
+		},
+	}
+	if accessToken != "" {
+		claims.AccessTokenHash = accessTokenHash(signingKey.Method, accessToken)
+	}
+
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	token.Header["kid"] = signingKey.ID
+
+	return token.SignedString(signingKey.Private)
+}
+
+// accessTokenHash is the left half of the hash of the access token, using the
+// hash function of the signing algorithm (OpenID Connect Core 3.1.3.6).
+func accessTokenHash(method jwt.SigningMethod, accessToken string) string {
+
+	hash := crypto.SHA256
+	switch method.Alg() {
+	case "HS384", "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "HS512", "RS512", "PS512", "ES512", "EdDSA":
+		hash = crypto.SHA512
+	}
+
+	digest := hash.New()
+	digest.Write([]byte(accessToken))
+	sum := digest.Sum(nil)
+	return encodeBase64URL(sum[:len(sum)/2])
+}
@@ -0,0 +1,41 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAccessTokenHash(t *testing.T) {
+
+	// The access token and at_hash of the examples in OpenID Connect Core
+	// appendix A, signed with RS256.
+	const accessToken = "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y"
+
+	sum384 := sha512.Sum384([]byte(accessToken))
+	sum512 := sha512.Sum512([]byte(accessToken))
+	sum256 := sha256.Sum256([]byte(accessToken))
+
+	tests := []struct {
+		method jwt.SigningMethod
+		want   string
+	}{
+		{jwt.SigningMethodRS256, "77QmUPtjPfzWtF2AnpK9RQ"},
+		{jwt.SigningMethodHS256, base64.RawURLEncoding.EncodeToString(sum256[:16])},
+		{jwt.SigningMethodES256, base64.RawURLEncoding.EncodeToString(sum256[:16])},
+		{jwt.SigningMethodPS384, base64.RawURLEncoding.EncodeToString(sum384[:24])},
+		{jwt.SigningMethodHS384, base64.RawURLEncoding.EncodeToString(sum384[:24])},
+		{jwt.SigningMethodES512, base64.RawURLEncoding.EncodeToString(sum512[:32])},
+		{jwt.SigningMethodEdDSA, base64.RawURLEncoding.EncodeToString(sum512[:32])},
+	}
+	for _, test := range tests {
+		t.Run(test.method.Alg(), func(t *testing.T) {
+			if got := accessTokenHash(test.method, accessToken); got != test.want {
+				t.Errorf("accessTokenHash = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"strings"
+)
+
+type (
+	// JWK is the public half of a signing key as described in RFC 7517.
+	JWK struct {
+		KeyType   string `json:"kty"`
+		Use       string `json:"use"`
+		KeyID     string `json:"kid"`
+		Algorithm string `json:"alg"`
+		N         string `json:"n,omitempty"`
+		E         string `json:"e,omitempty"`
+		Curve     string `json:"crv,omitempty"`
+		X         string `json:"x,omitempty"`
+		Y         string `json:"y,omitempty"`
+	}
+
+	JWKS struct {
+		Keys []JWK `json:"keys"`
+	}
+)
+
+// JWK returns the public JSON Web Key for k. HMAC keys have no public half
+// and report false.
+func (k *Key) JWK() (JWK, bool) {
+
+	jwk := JWK{Use: "sig", KeyID: k.ID, Algorithm: k.Method.Alg()}
+
+	switch public := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encodeBase64URL(public.N.Bytes())
+		jwk.E = encodeBase64URL(big.NewInt(int64(public.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = public.Curve.Params().Name
+		jwk.X = encodeBase64URL(public.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeBase64URL(public.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encodeBase64URL(public)
+	default:
+		return JWK{}, false
+	}
+
+	return jwk, true
+}
+
+// JWKS returns the public keys currently accepted for verification.
+func (k *Keyring) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range k.Keys() {
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// Algorithms returns the distinct signing algorithms in the keyring.
+func (k *Keyring) Algorithms() []string {
+	var algorithms []string
+	seen := map[string]bool{}
+	for _, key := range k.Keys() {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algorithms = append(algorithms, alg)
+		}
+	}
+	return algorithms
+}
+
+// ETag identifies the current set of verification keys so clients can
+// revalidate their cached JWKS cheaply.
+func (k *Keyring) ETag() string {
+	var ids []string
+	for _, key := range k.Keys() {
+		ids = append(ids, key.ID)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(ids, ",")))
+	return `"` + encodeBase64URL(sum[:12]) + `"`
+}
+
+func encodeBase64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
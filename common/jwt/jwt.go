@@ -2,31 +2,129 @@ package jwt
 
 import (
 	"errors"
-	"go-jwt/common/database"
+	"fmt"
 	"go-jwt/common/response"
+	"go-jwt/modules/client"
 	"go-jwt/modules/role"
 	"go-jwt/modules/user"
+	"os"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-var JWT_SIGNATURE_KEY = []byte("the secret of kalimdor")
+var (
+	policy      Policy
+	keyring     *Keyring
+	revocations *RevocationStore
+	sessions    *SessionStore
+	db          *gorm.DB
+)
+
+// InitJWT loads the token policy, the signing key from the provider selected
+// in the environment and the retired verification keys from
+// JWT_RETIRED_KEYS_DIR. The database is used by VerifyToken to check that the
+// token subject still exists.
+func InitJWT(database *gorm.DB) {
+	db = database
+	revocations = NewRevocationStore(database)
+	sessions = NewSessionStore(database)
+
+	var err error
+	if policy, err = LoadPolicyFromEnv(); err != nil {
+		panic(fmt.Sprintf("invalid JWT token policy: %v", err))
+	}
+
+	key, err := loadKeyFromEnv()
+	if err != nil {
+		panic(fmt.Sprintf("failed to load JWT signing key: %v", err))
+	}
+	grace, err := gracePeriodFromEnv()
+	if err != nil {
+		panic(fmt.Sprintf("invalid JWT_KEY_GRACE_PERIOD: %v", err))
+	}
+	keyring = NewKeyring(key, grace)
+
+	if dir := os.Getenv("JWT_RETIRED_KEYS_DIR"); dir != "" {
+		retired, err := LoadRetiredKeys(dir)
+		if err != nil {
+			panic(fmt.Sprintf("failed to load retired JWT keys: %v", err))
+		}
+		for _, key := range retired {
+			keyring.Retire(key, key.RetiredAt)
+		}
+	}
+}
+
+// GetPolicy returns the token policy loaded by InitJWT.
+func GetPolicy() Policy {
+	return policy
+}
+
+// GetKeyring returns the keyring loaded by InitJWT.
+func GetKeyring() *Keyring {
+	return keyring
+}
+
+// GetRevocationStore returns the store VerifyToken checks revoked tokens against.
+func GetRevocationStore() *RevocationStore {
+	return revocations
+}
+
+// GetSessionStore returns the store VerifyToken checks the sid claim against.
+func GetSessionStore() *SessionStore {
+	return sessions
+}
+
+// RevokeToken denylists the token described by claims until it expires.
+func RevokeToken(claims *Claims) error {
+	if claims.ID == "" {
+		return errors.New("token has no jti")
+	}
+	return revocations.RevokeToken(claims.ID, claims.Principal(), claims.ExpiresAt.Time)
+}
+
+// GenerateToken signs claims with the active key, filling in the issuer,
+// lifetime and a fresh jti. Claims without an audience get the default
+// audience of the policy; the lifetime depends on the audience.
+func GenerateToken(claims *Claims) (string, error) {
 
-func GenerateToken(username string, roleID uint) (string, error) {
+	signingKey := keyring.Active()
+	if !signingKey.CanSign() {
+		return "", errors.New("no private key configured for signing tokens")
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": username,
-		"role_id":  roleID,
-		"exp":      time.Now().Add(time.Hour * 1).Unix(),
-		"issuer":   "go-jwt",
-		"aud":      "go-jwt-client",
-	}, func(t *jwt.Token) {
-		t.Header["typ"] = "JWT"
-	})
+	if len(claims.Audience) == 0 {
+		claims.Audience = jwt.ClaimStrings{policy.DefaultAudience()}
+	}
+	for _, audience := range claims.Audience {
+		if !policy.Accepts(audience) {
+			return "", fmt.Errorf("audience %q is not allowed", audience)
+		}
+	}
 
-	tokenString, err := token.SignedString(JWT_SIGNATURE_KEY)
+	now := time.Now()
+
+	// A UUIDv7 jti records when the token was issued to the millisecond,
+	// which RevocationStore needs as iat is in whole seconds.
+	jti, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+
+	claims.ID = jti.String()
+	claims.Issuer = policy.Issuer
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(policy.TTL(claims.Audience[0])))
+
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	token.Header["kid"] = signingKey.ID
+
+	tokenString, err := token.SignedString(signingKey.Private)
 	if err != nil {
 		return tokenString, err
 	}
@@ -34,10 +132,22 @@ func GenerateToken(username string, roleID uint) (string, error) {
 	return tokenString, nil
 }
 
-func VerifyToken(tokenString string) (*jwt.MapClaims, error) {
+func VerifyToken(tokenString string) (*Claims, error) {
 
-	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+	var claims Claims
+
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		signingKey, ok := keyring.Lookup(kid)
+		if !ok {
+			return nil, &response.FailedResponseMessage{
+				Message: "Unknown signing key",
+				Status:  "failed",
+				Code:    fiber.StatusUnauthorized,
+				Errors:  nil,
+			}
+		}
+		if t.Method.Alg() != signingKey.Method.Alg() {
 			return nil, &response.FailedResponseMessage{
 				Message: "Invalid signing method",
 				Status:  "failed",
@@ -45,8 +155,13 @@ func VerifyToken(tokenString string) (*jwt.MapClaims, error) {
 				Errors:  nil,
 			}
 		}
-		return JWT_SIGNATURE_KEY, nil
-	})
+		return signingKey.Public, nil
+	},
+		jwt.WithIssuer(policy.Issuer),
+		jwt.WithLeeway(policy.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -57,63 +172,162 @@ func VerifyToken(tokenString string) (*jwt.MapClaims, error) {
 				Errors:  nil,
 			}
 		}
-		return nil, err
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
+		var responseErr *response.FailedResponseMessage
+		if errors.As(err, &responseErr) {
+			return nil, responseErr
+		}
 		return nil, &response.FailedResponseMessage{
 			Message: "invalid token",
 			Status:  "failed",
 			Code:    fiber.StatusUnauthorized,
-			Errors:  nil,
+			Errors:  err.Error(),
 		}
 	}
 
-	username := (claims)["username"].(string)
-	roleID := (claims)["role_id"].(float64)
-	exp := (claims)["exp"].(float64)
-	issuer := (claims)["issuer"].(string)
-	aud := (claims)["aud"].(string)
-
-	if time.Now().Unix() > int64(exp) {
+	if !token.Valid || (claims.Username == "" && claims.ClientID == "") || !acceptsAudience(claims.Audience) {
 		return nil, &response.FailedResponseMessage{
-			Message: "token expired",
+			Message: "invalid token",
 			Status:  "failed",
 			Code:    fiber.StatusUnauthorized,
 			Errors:  nil,
 		}
-	} else if issuer != "go-jwt" || aud != "go-jwt-client" {
+	}
+
+	if !claims.IsClientToken() {
+		if err := verifyUser(&claims); err != nil {
+			return nil, err
+		}
+	}
+	if claims.ClientID != "" {
+		if err := verifyClient(&claims); err != nil {
+			return nil, err
+		}
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	revoked, err := revocations.IsRevoked(claims.ID, claims.Principal(), issuedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
 		return nil, &response.FailedResponseMessage{
-			Message: "invalid token",
+			Message: "token revoked",
 			Status:  "failed",
 			Code:    fiber.StatusUnauthorized,
 			Errors:  nil,
 		}
-	} else {
-
-		db := database.GetDB()
+	}
 
-		var user user.User
-		if err := db.First(&user, "username = ?", username).Error; err != nil {
+	if claims.SessionID != "" {
+		revoked, err := sessions.IsRevoked(claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
 			return nil, &response.FailedResponseMessage{
-				Message: "invalid username",
+				Message: "session revoked",
 				Status:  "failed",
 				Code:    fiber.StatusUnauthorized,
-				Errors:  err.Error(),
+				Errors:  nil,
 			}
 		}
-		var role role.Role
-		if err := db.First(&role, roleID).Error; err != nil {
-			return nil, &response.FailedResponseMessage{
-				Message: "invalid role id",
+	}
+
+	return &claims, nil
+}
+
+// verifyUser checks that the user and the roles in the token still exist and
+// fills in the attributes that are loaded from the database.
+func verifyUser(claims *Claims) error {
+
+	var user user.User
+	if err := db.Preload("Roles").First(&user, "username = ?", claims.Username).Error; err != nil {
+		return &response.FailedResponseMessage{
+			Message: "invalid username",
+			Status:  "failed",
+			Code:    fiber.StatusUnauthorized,
+			Errors:  err.Error(),
+		}
+	}
+	if !hasRoles(user.Roles, claims.Roles) {
+		return &response.FailedResponseMessage{
+			Message: "invalid role id",
+			Status:  "failed",
+			Code:    fiber.StatusUnauthorized,
+			Errors:  "a role in the token is no longer assigned to the user",
+		}
+	}
+
+	claims.Department = user.Department
+	for _, role := range user.Roles {
+		for _, id := range claims.Roles {
+			if role.ID == id {
+				claims.RoleNames = append(claims.RoleNames, role.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// verifyClient checks that the client still exists and may still use every
+// scope in the token.
+func verifyClient(claims *Claims) error {
+
+	var clients []client.Client
+	if err := db.Where(&client.Client{ClientID: claims.ClientID}).Limit(1).Find(&clients).Error; err != nil {
+		return err
+	}
+	if len(clients) == 0 {
+		return &response.FailedResponseMessage{
+			Message: "invalid client",
+			Status:  "failed",
+			Code:    fiber.StatusUnauthorized,
+			Errors:  nil,
+		}
+	}
+
+	allowed := map[string]bool{}
+	for _, scope := range clients[0].Scopes() {
+		allowed[scope] = true
+	}
+	for _, scope := range claims.Scopes() {
+		if !allowed[scope] {
+			return &response.FailedResponseMessage{
+				Message: "invalid scope",
 				Status:  "failed",
 				Code:    fiber.StatusUnauthorized,
-				Errors:  err.Error(),
+				Errors:  "scope " + scope + " is no longer allowed for the client",
 			}
 		}
+	}
+	return nil
+}
 
+// hasRoles reports whether every role ID in the token is still assigned to the
+// user. Tokens carrying a role that was unassigned or deleted are rejected.
+func hasRoles(assigned []role.Role, claimed []uint) bool {
+	ids := map[uint]bool{}
+	for _, role := range assigned {
+		ids[role.ID] = true
 	}
+	for _, id := range claimed {
+		if !ids[id] {
+			return false
+		}
+	}
+	return true
+}
 
-	return &claims, nil
+// acceptsAudience reports whether any of the token audiences is accepted by
+// the policy. The parser option only checks for a single audience.
+func acceptsAudience(audiences jwt.ClaimStrings) bool {
+	for _, audience := range audiences {
+		if policy.Accepts(audience) {
+			return true
+		}
+	}
+	return false
 }
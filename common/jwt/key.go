@@ -0,0 +1,191 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a signing method together with its key material. Private is nil for
+// keys that can only be used to verify tokens.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   interface{}
+	Public    interface{}
+	RetiredAt time.Time
+}
+
+// KeyProvider loads the key used to sign and verify tokens.
+type KeyProvider interface {
+	LoadKey() (*Key, error)
+}
+
+// EnvKeyProvider reads PEM encoded keys (or the HMAC secret) from environment variables.
+type EnvKeyProvider struct {
+	Algorithm     string
+	PrivateKeyEnv string
+	PublicKeyEnv  string
+}
+
+// FileKeyProvider reads PEM encoded keys (or the HMAC secret) from files.
+type FileKeyProvider struct {
+	Algorithm      string
+	PrivateKeyPath string
+	PublicKeyPath  string
+}
+
+func (p *EnvKeyProvider) LoadKey() (*Key, error) {
+	return ParseKey(p.Algorithm, []byte(os.Getenv(p.PrivateKeyEnv)), []byte(os.Getenv(p.PublicKeyEnv)))
+}
+
+func (p *FileKeyProvider) LoadKey() (*Key, error) {
+	private, err := readKeyFile(p.PrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	public, err := readKeyFile(p.PublicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKey(p.Algorithm, private, public)
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// NewKeyProviderFromEnv builds the provider selected by JWT_KEY_PROVIDER ("env" or "file").
+func NewKeyProviderFromEnv() (KeyProvider, error) {
+	algorithm := os.Getenv("JWT_SIGNING_ALG")
+	if algorithm == "" {
+		algorithm = jwt.SigningMethodHS256.Alg()
+	}
+
+	switch strings.ToLower(os.Getenv("JWT_KEY_PROVIDER")) {
+	case "", "env":
+		provider := &EnvKeyProvider{
+			Algorithm:     algorithm,
+			PrivateKeyEnv: "JWT_PRIVATE_KEY",
+			PublicKeyEnv:  "JWT_PUBLIC_KEY",
+		}
+		if isHMAC(algorithm) {
+			provider.PrivateKeyEnv = "JWT_SECRET"
+			provider.PublicKeyEnv = ""
+		}
+		return provider, nil
+	case "file":
+		return &FileKeyProvider{
+			Algorithm:      algorithm,
+			PrivateKeyPath: os.Getenv("JWT_PRIVATE_KEY_FILE"),
+			PublicKeyPath:  os.Getenv("JWT_PUBLIC_KEY_FILE"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown JWT_KEY_PROVIDER %q", os.Getenv("JWT_KEY_PROVIDER"))
+	}
+}
+
+func isHMAC(algorithm string) bool {
+	_, ok := jwt.GetSigningMethod(algorithm).(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// ParseKey builds a Key for the given algorithm. For HMAC algorithms private is
+// the shared secret; otherwise both values are PEM blocks and either may be
+// empty as long as one of them is set.
+func ParseKey(algorithm string, private, public []byte) (*Key, error) {
+
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil || method == jwt.SigningMethodNone {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	key := &Key{Method: method}
+
+	if len(private) == 0 && len(public) == 0 {
+		return nil, fmt.Errorf("no key configured for %s", algorithm)
+	}
+
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(private) == 0 {
+			return nil, errors.New("HMAC secret is empty")
+		}
+		key.Private = private
+		key.Public = private
+
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if len(private) != 0 {
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(private)
+			if err != nil {
+				return nil, err
+			}
+			key.Private = privateKey
+			key.Public = &privateKey.PublicKey
+		} else {
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(public)
+			if err != nil {
+				return nil, err
+			}
+			key.Public = publicKey
+		}
+
+	case *jwt.SigningMethodECDSA:
+		if len(private) != 0 {
+			privateKey, err := jwt.ParseECPrivateKeyFromPEM(private)
+			if err != nil {
+				return nil, err
+			}
+			key.Private = privateKey
+			key.Public = &privateKey.PublicKey
+		} else {
+			publicKey, err := jwt.ParseECPublicKeyFromPEM(public)
+			if err != nil {
+				return nil, err
+			}
+			key.Public = publicKey
+		}
+		if curve := key.Public.(*ecdsa.PublicKey).Curve.Params().BitSize; curve != method.(*jwt.SigningMethodECDSA).CurveBits {
+			return nil, fmt.Errorf("%s requires a P-%d key, got P-%d", algorithm, method.(*jwt.SigningMethodECDSA).CurveBits, curve)
+		}
+
+	case *jwt.SigningMethodEd25519:
+		if len(private) != 0 {
+			privateKey, err := jwt.ParseEdPrivateKeyFromPEM(private)
+			if err != nil {
+				return nil, err
+			}
+			key.Private = privateKey
+			key.Public = privateKey.(ed25519.PrivateKey).Public()
+		} else {
+			publicKey, err := jwt.ParseEdPublicKeyFromPEM(public)
+			if err != nil {
+				return nil, err
+			}
+			key.Public = publicKey
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	return key, nil
+}
+
+// CanSign reports whether the key holds private material.
+func (k *Key) CanSign() bool {
+	return k.Private != nil
+}
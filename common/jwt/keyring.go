@@ -0,0 +1,288 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/joho/godotenv"
+)
+
+// Keyring holds the active signing key and the retired keys that are still
+// accepted for verification until their grace period ends.
+type Keyring struct {
+	mu     sync.RWMutex
+	active *Key
+	keys   map[string]*Key
+	grace  time.Duration
+}
+
+func NewKeyring(active *Key, grace time.Duration) *Keyring {
+	return &Keyring{
+		active: active,
+		keys:   map[string]*Key{active.ID: active},
+		grace:  grace,
+	}
+}
+
+// Active returns the key new tokens are signed with.
+func (k *Keyring) Active() *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Lookup returns the verification key with the given kid. An empty kid
+// resolves to the active key so tokens issued before kid headers existed keep working.
+// So does an unknown kid while the active key is an HMAC secret: without
+// JWT_KEY_ID its kid is random, and differs between instances and restarts.
+func (k *Keyring) Lookup(kid string) (*Key, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if kid == "" {
+		return k.active, true
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		if _, symmetric := k.active.Public.([]byte); symmetric {
+			return k.active, true
+		}
+		return nil, false
+	}
+	if k.expired(key, time.Now()) {
+		return nil, false
+	}
+	return key, true
+}
+
+// Keys returns every key currently accepted for verification, active key first.
+func (k *Keyring) Keys() []*Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now()
+	keys := []*Key{k.active}
+	for _, key := range k.keys {
+		if key != k.active && !k.expired(key, now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.SliceStable(keys[1:], func(i, j int) bool {
+		return keys[1+i].RetiredAt.After(keys[1+j].RetiredAt)
+	})
+	return keys
+}
+
+// Rotate makes key the active signing key and retires the previous one.
+// Rotating to the key that is already active is a no-op.
+func (k *Keyring) Rotate(key *Key) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if key.ID == k.active.ID || sameSecret(key, k.active) {
+		return
+	}
+
+	now := time.Now()
+	k.active.RetiredAt = now
+	key.RetiredAt = time.Time{}
+	k.active = key
+	k.keys[key.ID] = key
+
+	for kid, existing := range k.keys {
+		if k.expired(existing, now) {
+			delete(k.keys, kid)
+		}
+	}
+}
+
+// Retire registers a verification-only key that was retired at the given time.
+func (k *Keyring) Retire(key *Key, retiredAt time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if key.ID == k.active.ID {
+		return
+	}
+	key.RetiredAt = retiredAt
+	k.keys[key.ID] = key
+}
+
+// sameSecret reports whether both keys are the same HMAC secret, which gets a
+// new random kid every time it is loaded.
+func sameSecret(a, b *Key) bool {
+	secretA, okA := a.Public.([]byte)
+	secretB, okB := b.Public.([]byte)
+	return okA && okB && hmac.Equal(secretA, secretB)
+}
+
+func (k *Keyring) expired(key *Key, now time.Time) bool {
+	return !key.RetiredAt.IsZero() && now.After(key.RetiredAt.Add(k.grace))
+}
+
+// KeyID derives a stable identifier for a key from its public half. HMAC keys
+// get a random one instead, as anything derived from the secret would let
+// whoever reads a token header check guesses of the secret against it.
+func KeyID(key *Key) (string, error) {
+	if _, symmetric := key.Public.([]byte); symmetric {
+		random := make([]byte, 12)
+		if _, err := rand.Read(random); err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(random), nil
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(key.Public)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+// LoadRetiredKeys reads PEM encoded public keys named <kid>.pem or
+// <kid>.<alg>.pem from dir. Without an algorithm in the name it is inferred
+// from the key type, and RSA keys are taken to be RS256. The file
+// modification time is taken as the moment the key was retired.
+func LoadRetiredKeys(dir string) ([]*Key, error) {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		kid, algorithm := strings.TrimSuffix(entry.Name(), ".pem"), ""
+		if i := strings.LastIndex(kid, "."); i > 0 && jwt.GetSigningMethod(kid[i+1:]) != nil {
+			kid, algorithm = kid[:i], kid[i+1:]
+		}
+
+		key, err := parsePublicKey(algorithm, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		key.ID = kid
+		key.RetiredAt = info.ModTime()
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func parsePublicKey(algorithm string, data []byte) (*Key, error) {
+
+	if algorithm != "" {
+		if isHMAC(algorithm) {
+			return nil, fmt.Errorf("%s keys are secret and cannot be loaded as public keys", algorithm)
+		}
+		return ParseKey(algorithm, nil, data)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{Public: public}
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch public.Curve.Params().BitSize {
+		case 256:
+			key.Method = jwt.SigningMethodES256
+		case 384:
+			key.Method = jwt.SigningMethodES384
+		case 521:
+			key.Method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", public.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", public)
+	}
+
+	return key, nil
+}
+
+// loadKeyFromEnv loads the active key described by the environment and
+// assigns its kid from JWT_KEY_ID, falling back to a derived identifier.
+func loadKeyFromEnv() (*Key, error) {
+	provider, err := NewKeyProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	key, err := provider.LoadKey()
+	if err != nil {
+		return nil, err
+	}
+	if key.ID = os.Getenv("JWT_KEY_ID"); key.ID == "" {
+		if key.ID, err = KeyID(key); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+func gracePeriodFromEnv() (time.Duration, error) {
+	value := os.Getenv("JWT_KEY_GRACE_PERIOD")
+	if value == "" {
+		return time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// ReloadKeys re-reads the .env file and key material and rotates the keyring
+// when the configured key has changed. The previous key stays valid for
+// verification for the grace period.
+func ReloadKeys() error {
+
+	if err := godotenv.Overload(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	key, err := loadKeyFromEnv()
+	if err != nil {
+		return err
+	}
+
+	keyring.Rotate(key)
+	return nil
+}
@@ -0,0 +1,226 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newRSAKey(t *testing.T, id string, method jwt.SigningMethod) *Key {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: id, Method: method, Private: private, Public: &private.PublicKey}
+}
+
+func newHMACKey(id, secret string) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, Private: []byte(secret), Public: []byte(secret)}
+}
+
+func writePublicKey(t *testing.T, dir, name string, public interface{}, modified time.Time) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// verify parses a token the way VerifyToken does, picking the key by kid.
+func verify(keyring *Keyring, signed string) error {
+	_, err := jwt.Parse(signed, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keyring.Lookup(kid)
+		if !ok {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+		return key.Public, nil
+	}, jwt.WithValidMethods([]string{"RS256", "PS384", "HS256"}))
+	return err
+}
+
+func sign(t *testing.T, key *Key) string {
+	t.Helper()
+	token := jwt.NewWithClaims(key.Method, jwt.RegisteredClaims{Subject: "1"})
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestKeyringLookup(t *testing.T) {
+
+	active := newRSAKey(t, "active", jwt.SigningMethodRS256)
+	recent := newRSAKey(t, "recent", jwt.SigningMethodRS256)
+	old := newRSAKey(t, "old", jwt.SigningMethodRS256)
+
+	keyring := NewKeyring(active, time.Hour)
+	keyring.Retire(recent, time.Now().Add(-30*time.Minute))
+	keyring.Retire(old, time.Now().Add(-2*time.Hour))
+
+	tests := []struct {
+		kid  string
+		want *Key
+	}{
+		{"active", active},
+		{"", active},
+		{"recent", recent},
+		{"old", nil},
+		{"unknown", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.kid, func(t *testing.T) {
+			key, ok := keyring.Lookup(test.kid)
+			if key != test.want || ok != (test.want != nil) {
+				t.Errorf("Lookup(%q) = %v, %v, want %v", test.kid, key, ok, test.want)
+			}
+		})
+	}
+
+	if keys := keyring.Keys(); len(keys) != 2 || keys[0] != active || keys[1] != recent {
+		t.Errorf("Keys = %v, want the active and the recently retired key", keys)
+	}
+}
+
+func TestKeyringLookupHMAC(t *testing.T) {
+
+	// Without JWT_KEY_ID every instance gives the same secret its own random
+	// kid, so tokens from other instances carry a kid this one does not know.
+	active := newHMACKey("this-instance", "secret")
+	keyring := NewKeyring(active, time.Hour)
+
+	for _, kid := range []string{"this-instance", "other-instance", ""} {
+		if key, ok := keyring.Lookup(kid); !ok || key != active {
+			t.Errorf("Lookup(%q) = %v, %v, want the active key", kid, key, ok)
+		}
+	}
+
+	if err := verify(keyring, sign(t, newHMACKey("other-instance", "secret"))); err != nil {
+		t.Errorf("token of another instance: %v", err)
+	}
+	if err := verify(keyring, sign(t, newHMACKey("other-instance", "other secret"))); err == nil {
+		t.Errorf("token signed with another secret verified")
+	}
+}
+
+func TestKeyringRotate(t *testing.T) {
+
+	first := newRSAKey(t, "first", jwt.SigningMethodRS256)
+	second := newRSAKey(t, "second", jwt.SigningMethodRS256)
+
+	keyring := NewKeyring(first, time.Hour)
+	issuedBefore := sign(t, first)
+
+	keyring.Rotate(second)
+	if keyring.Active() != second {
+		t.Fatalf("Active = %s, want second", keyring.Active().ID)
+	}
+	if err := verify(keyring, issuedBefore); err != nil {
+		t.Errorf("token of the retired key during the grace period: %v", err)
+	}
+	if err := verify(keyring, sign(t, second)); err != nil {
+		t.Errorf("token of the new key: %v", err)
+	}
+
+	first.RetiredAt = time.Now().Add(-2 * time.Hour)
+	if err := verify(keyring, issuedBefore); err == nil {
+		t.Errorf("token of the retired key verified after the grace period")
+	}
+
+	// Reloading an unchanged HMAC secret gives it a new random kid, which must
+	// not retire the secret in favour of itself.
+	secret := newHMACKey("loaded", "secret")
+	hmacRing := NewKeyring(secret, time.Hour)
+	hmacRing.Rotate(newHMACKey("reloaded", "secret"))
+	if hmacRing.Active() != secret || len(hmacRing.Keys()) != 1 {
+		t.Errorf("rotating to the same secret changed the keyring")
+	}
+}
+
+func TestLoadRetiredKeys(t *testing.T) {
+
+	dir := t.TempDir()
+	retired := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+
+	pss := newRSAKey(t, "", jwt.SigningMethodPS384)
+	legacy := newRSAKey(t, "", jwt.SigningMethodRS256)
+	ec, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePublicKey(t, dir, "pss.PS384.pem", pss.Public, retired)
+	writePublicKey(t, dir, "legacy.pem", legacy.Public, retired)
+	writePublicKey(t, dir, "v1.2.pem", &ec.PublicKey, retired)
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadRetiredKeys(dir)
+	if err != nil {
+		t.Fatalf("LoadRetiredKeys: %v", err)
+	}
+
+	want := map[string]jwt.SigningMethod{
+		"pss":    jwt.SigningMethodPS384,
+		"legacy": jwt.SigningMethodRS256,
+		"v1.2":   jwt.SigningMethodES384,
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("loaded %d keys, want %d", len(keys), len(want))
+	}
+	for _, key := range keys {
+		method, ok := want[key.ID]
+		if !ok {
+			t.Errorf("unexpected kid %q", key.ID)
+			continue
+		}
+		if key.Method != method {
+			t.Errorf("kid %s: method %s, want %s", key.ID, key.Method.Alg(), method.Alg())
+		}
+		if key.Private != nil {
+			t.Errorf("kid %s: retired keys must not be able to sign", key.ID)
+		}
+		if !key.RetiredAt.Equal(retired) {
+			t.Errorf("kid %s: retired at %s, want %s", key.ID, key.RetiredAt, retired)
+		}
+	}
+
+	// A PS384 token signed before the key was retired verifies with the
+	// loaded key, which it would not if the key were taken to be RS256.
+	keyring := NewKeyring(newRSAKey(t, "active", jwt.SigningMethodRS256), time.Hour)
+	for _, key := range keys {
+		keyring.Retire(key, key.RetiredAt)
+	}
+	pss.ID = "pss"
+	if err := verify(keyring, sign(t, pss)); err != nil {
+		t.Errorf("token of the retired PS384 key: %v", err)
+	}
+
+	secretDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(secretDir, "old.HS256.pem"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRetiredKeys(secretDir); err == nil {
+		t.Errorf("LoadRetiredKeys accepted an HMAC secret")
+	}
+}
@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Policy controls the registered claims of issued tokens and how strictly they
+// are checked on verification.
+type Policy struct {
+	Issuer string
+	// Audiences lists the accepted audiences. The first one is used when a
+	// token is requested without an audience.
+	Audiences    []string
+	DefaultTTL   time.Duration
+	AudienceTTLs map[string]time.Duration
+	Leeway       time.Duration
+}
+
+// LoadPolicyFromEnv reads the token policy:
+//
+//	JWT_ISSUER            issuer, default "go-jwt"
+//	JWT_AUDIENCES         comma separated accepted audiences, default "go-jwt-client"
+//	JWT_ACCESS_TOKEN_TTL  default access token lifetime, default 1h
+//	JWT_AUDIENCE_TTLS     per audience lifetimes, e.g. "go-jwt-mobile=24h,go-jwt-web=15m"
+//	JWT_LEEWAY            allowed clock skew for exp, nbf and iat, default 30s
+func LoadPolicyFromEnv() (Policy, error) {
+
+	policy := Policy{
+		Issuer:       "go-jwt",
+		Audiences:    []string{"go-jwt-client"},
+		DefaultTTL:   time.Hour,
+		AudienceTTLs: map[string]time.Duration{},
+		Leeway:       30 * time.Second,
+	}
+
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		policy.Issuer = issuer
+	}
+
+	if audiences := splitList(os.Getenv("JWT_AUDIENCES")); len(audiences) != 0 {
+		policy.Audiences = audiences
+	}
+
+	if value := os.Getenv("JWT_ACCESS_TOKEN_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			return Policy{}, fmt.Errorf("invalid JWT_ACCESS_TOKEN_TTL %q", value)
+		}
+		policy.DefaultTTL = ttl
+	}
+
+	for _, entry := range splitList(os.Getenv("JWT_AUDIENCE_TTLS")) {
+		audience, value, found := strings.Cut(entry, "=")
+		ttl, err := time.ParseDuration(value)
+		if !found || err != nil || ttl <= 0 {
+			return Policy{}, fmt.Errorf("invalid JWT_AUDIENCE_TTLS entry %q", entry)
+		}
+		if !policy.Accepts(audience) {
+			return Policy{}, fmt.Errorf("JWT_AUDIENCE_TTLS entry %q is not listed in JWT_AUDIENCES", audience)
+		}
+		policy.AudienceTTLs[audience] = ttl
+	}
+
+	if value := os.Getenv("JWT_LEEWAY"); value != "" {
+		leeway, err := time.ParseDuration(value)
+		if err != nil || leeway < 0 {
+			return Policy{}, fmt.Errorf("invalid JWT_LEEWAY %q", value)
+		}
+		policy.Leeway = leeway
+	}
+
+	return policy, nil
+}
+
+// DefaultAudience is the audience of tokens requested without one.
+func (p Policy) DefaultAudience() string {
+	return p.Audiences[0]
+}
+
+// Accepts reports whether audience is one of the configured audiences.
+func (p Policy) Accepts(audience string) bool {
+	for _, accepted := range p.Audiences {
+		if accepted == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// TTL returns the access token lifetime for audience.
+func (p Policy) TTL(audience string) time.Duration {
+	if ttl, ok := p.AudienceTTLs[audience]; ok {
+		return ttl
+	}
+	return p.DefaultTTL
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
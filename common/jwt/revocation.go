@@ -0,0 +1,193 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// negativeCacheTTL bounds how long a "not revoked" answer is cached, and so
+// how long a revocation made by another instance can go unnoticed.
+const negativeCacheTTL = 30 * time.Second
+
+type (
+	// RevokedToken denylists a single token by jti until it would have expired anyway.
+	RevokedToken struct {
+		JTI       string    `gorm:"primarykey" json:"jti"`
+		Username  string    `gorm:"not null;index" json:"username"`
+		ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// UserTokenRevocation rejects every token of the user issued before RevokedAt.
+	UserTokenRevocation struct {
+		Username  string    `gorm:"primarykey" json:"username"`
+		RevokedAt time.Time `gorm:"not null" json:"revoked_at"`
+	}
+)
+
+type cacheEntry struct {
+	revoked bool
+	cutoff  time.Time
+	expires time.Time
+}
+
+// RevocationStore persists revocations and caches lookups in memory. Revoked
+// jtis are cached for the remaining lifetime of the token.
+type RevocationStore struct {
+	db    *gorm.DB
+	mu    sync.RWMutex
+	jtis  map[string]cacheEntry
+	users map[string]cacheEntry
+}
+
+func NewRevocationStore(db *gorm.DB) *RevocationStore {
+	return &RevocationStore{
+		db:    db,
+		jtis:  map[string]cacheEntry{},
+		users: map[string]cacheEntry{},
+	}
+}
+
+// RevokeToken denylists jti until expiresAt.
+func (s *RevocationStore) RevokeToken(jti, username string, expiresAt time.Time) error {
+
+	now := time.Now()
+	if !expiresAt.After(now) {
+		return nil
+	}
+
+	err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&RevokedToken{
+		JTI:       jti,
+		Username:  username,
+		ExpiresAt: expiresAt,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Where("expires_at < ?", now).Delete(&RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.jtis[jti] = cacheEntry{revoked: true, expires: expiresAt}
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeAllForUser rejects every token issued to username up to now.
+func (s *RevocationStore) RevokeAllForUser(username string) error {
+
+	now := time.Now()
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "username"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_at"}),
+	}).Create(&UserTokenRevocation{Username: username, RevokedAt: now}).Error
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.users[username] = cacheEntry{cutoff: now, expires: now.Add(negativeCacheTTL)}
+	s.mu.Unlock()
+	return nil
+}
+
+// IsRevoked reports whether the token identified by jti, issued to username
+// at issuedAt, has been revoked individually or as part of a per-user revocation.
+func (s *RevocationStore) IsRevoked(jti, username string, issuedAt time.Time) (bool, error) {
+
+	cutoff, err := s.userCutoff(username)
+	if err != nil {
+		return false, err
+	}
+	// iat is in whole seconds, so a token issued in the second of the cutoff
+	// only survives if its jti shows it was issued after it.
+	if issuedAt.Unix() < cutoff.Unix() || (issuedAt.Unix() == cutoff.Unix() && !issuedAfter(jti, cutoff)) {
+		return true, nil
+	}
+
+	if jti == "" {
+		return false, nil
+	}
+
+	now := time.Now()
+
+	s.mu.RLock()
+	entry, ok := s.jtis[jti]
+	s.mu.RUnlock()
+	if ok && now.Before(entry.expires) {
+		return entry.revoked, nil
+	}
+
+	// Find instead of First: a miss is the common case and must not be logged as an error.
+	var revoked []RevokedToken
+	if err := s.db.Where(&RevokedToken{JTI: jti}).Limit(1).Find(&revoked).Error; err != nil {
+		return false, err
+	}
+	if len(revoked) == 0 {
+		entry = cacheEntry{revoked: false, expires: now.Add(negativeCacheTTL)}
+	} else {
+		entry = cacheEntry{revoked: true, expires: revoked[0].ExpiresAt}
+	}
+
+	s.mu.Lock()
+	s.jtis[jti] = entry
+	s.mu.Unlock()
+
+	return entry.revoked, nil
+}
+
+// issuedAfter reports whether jti is a UUIDv7 generated after t, to the
+// millisecond. Other jtis do not say when they were issued.
+func issuedAfter(jti string, t time.Time) bool {
+	id, err := uuid.Parse(jti)
+	if err != nil || id.Version() != 7 {
+		return false
+	}
+	sec, nsec := id.Time().UnixTime()
+	return time.Unix(sec, nsec).UnixMilli() > t.UnixMilli()
+}
+
+func (s *RevocationStore) userCutoff(username string) (time.Time, error) {
+
+	now := time.Now()
+
+	s.mu.RLock()
+	entry, ok := s.users[username]
+	s.mu.RUnlock()
+	if ok && now.Before(entry.expires) {
+		return entry.cutoff, nil
+	}
+
+	var revocation UserTokenRevocation
+	if err := s.db.Where(&UserTokenRevocation{Username: username}).Limit(1).Find(&revocation).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	s.mu.Lock()
+	s.users[username] = cacheEntry{cutoff: revocation.RevokedAt, expires: now.Add(negativeCacheTTL)}
+	s.pruneLocked(now)
+	s.mu.Unlock()
+
+	return revocation.RevokedAt, nil
+}
+
+// pruneLocked drops expired cache entries. The caller must hold s.mu.
+func (s *RevocationStore) pruneLocked(now time.Time) {
+	for jti, entry := range s.jtis {
+		if now.After(entry.expires) {
+			delete(s.jtis, jti)
+		}
+	}
+	for username, entry := range s.users {
+		if now.After(entry.expires) {
+			delete(s.users, username)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package jwt
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// uuidV7At returns a version 7 UUID carrying the millisecond of t.
+func uuidV7At(t time.Time) string {
+	var id uuid.UUID
+	var millis [8]byte
+	binary.BigEndian.PutUint64(millis[:], uint64(t.UnixMilli()))
+	copy(id[:6], millis[2:])
+	id[6] = 0x70
+	id[8] = 0x80
+	return id.String()
+}
+
+func TestIsRevokedByUserCutoff(t *testing.T) {
+
+	cutoff := time.Date(2024, 5, 1, 12, 0, 0, int(500*time.Millisecond), time.UTC)
+	second := cutoff.Truncate(time.Second)
+
+	tests := []struct {
+		name     string
+		jti      string
+		issuedAt time.Time
+		revoked  bool
+	}{
+		{"earlier second", uuidV7At(second.Add(-time.Second)), second.Add(-time.Second), true},
+		{"same second, before the cutoff", uuidV7At(cutoff.Add(-100 * time.Millisecond)), second, true},
+		{"same second, at the cutoff", uuidV7At(cutoff), second, true},
+		{"same second, after the cutoff", uuidV7At(cutoff.Add(100 * time.Millisecond)), second, false},
+		{"same second, random jti", uuid.NewString(), second, true},
+		{"same second, no jti", "", second, true},
+		{"later second", uuidV7At(second.Add(time.Second)), second.Add(time.Second), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			store := NewRevocationStore(nil)
+			expires := time.Now().Add(time.Hour)
+			store.users["alice"] = cacheEntry{cutoff: cutoff, expires: expires}
+			store.jtis[test.jti] = cacheEntry{revoked: false, expires: expires}
+
+			revoked, err := store.IsRevoked(test.jti, "alice", test.issuedAt)
+			if err != nil {
+				t.Fatalf("IsRevoked: %v", err)
+			}
+			if revoked != test.revoked {
+				t.Errorf("IsRevoked = %v, want %v", revoked, test.revoked)
+			}
+		})
+	}
+}
+
+func TestIssuedAfter(t *testing.T) {
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, int(500*time.Millisecond), time.UTC)
+
+	tests := []struct {
+		name string
+		jti  string
+		want bool
+	}{
+		{"a millisecond later", uuidV7At(at.Add(time.Millisecond)), true},
+		{"same millisecond", uuidV7At(at.Add(900 * time.Microsecond)), false},
+		{"a millisecond earlier", uuidV7At(at.Add(-time.Millisecond)), false},
+		{"version 4", uuid.NewString(), false},
+		{"not a uuid", "jti", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := issuedAfter(test.jti, at); got != test.want {
+				t.Errorf("issuedAfter(%s) = %v, want %v", test.jti, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is one sign-in of a user, from the login until its refresh tokens
+// expire. Its ID is the sid claim of every token issued in it, and the family
+// of its refresh tokens.
+type Session struct {
+	ID         string     `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	ClientID   string     `json:"client_id,omitempty"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	LastSeenAt time.Time  `gorm:"not null" json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// Current marks the session of the token that listed the sessions.
+	Current bool `gorm:"-" json:"current"`
+}
+
+// SessionStore persists sessions and caches whether they are revoked, like
+// RevocationStore. Looking a session up also records it as seen, so
+// LastSeenAt lags by at most the cache lifetime.
+type SessionStore struct {
+	db      *gorm.DB
+	mu      sync.RWMutex
+	revoked map[string]cacheEntry
+}
+
+func NewSessionStore(db *gorm.DB) *SessionStore {
+	return &SessionStore{db: db, revoked: map[string]cacheEntry{}}
+}
+
+func (s *SessionStore) Create(session Session) (Session, error) {
+	if err := s.db.Create(&session).Error; err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// Touch records that the session was used and extends it to expiresAt. A
+// revoked session is returned as it is, and a missing one as
+// gorm.ErrRecordNotFound.
+func (s *SessionStore) Touch(id string, expiresAt time.Time) (Session, error) {
+
+	var session Session
+	if err := s.db.Where("id = ?", id).First(&session).Error; err != nil {
+		return Session{}, err
+	}
+	if session.RevokedAt != nil {
+		return session, nil
+	}
+
+	session.LastSeenAt = time.Now()
+	session.ExpiresAt = expiresAt
+	err := s.db.Model(&Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_seen_at": session.LastSeenAt,
+		"expires_at":   session.ExpiresAt,
+	}).Error
+	if err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// FindActive returns the sessions of the user that are neither revoked nor
+// expired, the most recently used first.
+func (s *SessionStore) FindActive(userID uint) ([]Session, error) {
+	var sessions []Session
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).Order("last_seen_at DESC").Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Revoke ends the session of the user and reports false when there is no
+// such active session.
+func (s *SessionStore) Revoke(userID uint, id string) (bool, error) {
+
+	now := time.Now()
+	result := s.db.Model(&Session{}).Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).Update("revoked_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	s.mu.Lock()
+	s.revoked[id] = cacheEntry{revoked: true, expires: now.Add(negativeCacheTTL)}
+	s.mu.Unlock()
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeAllForUser ends every session of the user.
+func (s *SessionStore) RevokeAllForUser(userID uint) error {
+
+	var ids []string
+	if err := s.db.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if err := s.db.Model(&Session{}).Where("id IN ?", ids).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for _, id := range ids {
+		s.revoked[id] = cacheEntry{revoked: true, expires: now.Add(negativeCacheTTL)}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// IsRevoked reports whether the session has been revoked. Sessions that do
+// not exist count as revoked.
+func (s *SessionStore) IsRevoked(id string) (bool, error) {
+
+	now := time.Now()
+
+	s.mu.RLock()
+	entry, ok := s.revoked[id]
+	s.mu.RUnlock()
+	if ok && now.Before(entry.expires) {
+		return entry.revoked, nil
+	}
+
+	// Find instead of First: an unknown sid must not be logged as an error.
+	var sessions []Session
+	if err := s.db.Where("id = ?", id).Limit(1).Find(&sessions).Error; err != nil {
+		return false, err
+	}
+	revoked := len(sessions) == 0 || sessions[0].RevokedAt != nil
+
+	if !revoked {
+		if err := s.db.Model(&Session{}).Where("id = ?", id).UpdateColumn("last_seen_at", now).Error; err != nil {
+			return false, err
+		}
+	}
+
+	s.mu.Lock()
+	s.revoked[id] = cacheEntry{revoked: revoked, expires: now.Add(negativeCacheTTL)}
+	for cached, entry := range s.revoked {
+		if now.After(entry.expires) {
+			delete(s.revoked, cached)
+		}
+	}
+	s.mu.Unlock()
+
+	return revoked, nil
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"errors"
 	"go-jwt/common/jwt"
+	"go-jwt/common/policy"
 	"go-jwt/common/response"
 	"strings"
 
@@ -41,6 +42,21 @@ func JwtAuthorization(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).JSON(response.FailedResponseMessage{Code: fiber.StatusUnauthorized, Message: "Invalid token", Status: "failed", Errors: err.Error()})
 	}
 
-	c.Locals("claims", claims)
+	jwt.SetClaims(c, claims)
+	if claims.IsClientToken() {
+		policy.SetSubject(c, policy.Attributes{
+			"id":        claims.Subject,
+			"client_id": claims.ClientID,
+			"scope":     claims.Scopes(),
+		})
+	} else {
+		policy.SetSubject(c, policy.Attributes{
+			"id":         claims.Subject,
+			"username":   claims.Username,
+			"roles":      claims.RoleNames,
+			"role_ids":   claims.Roles,
+			"department": claims.Department,
+		})
+	}
 	return c.Next()
 }
@@ -6,6 +6,9 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+// LoggerMiddleware logs each request and the status of its response. Bodies
+// are not logged, since they carry tokens, TOTP secrets, recovery codes and
+// client secrets.
 func LoggerMiddleware(c *fiber.Ctx) error {
 
 	log.Printf("Request: %s %s", c.Method(), c.Path())
@@ -15,6 +18,6 @@ func LoggerMiddleware(c *fiber.Ctx) error {
 		log.Printf("Error: %v", err)
 	}
 
-	log.Printf("Response: %d %s", c.Response().StatusCode(), string(c.Response().Body()))
+	log.Printf("Response: %d %d bytes", c.Response().StatusCode(), len(c.Response().Body()))
 	return nil
 }
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"go-jwt/common/jwt"
+	"go-jwt/common/response"
+	"go-jwt/modules/role"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var permissionCache *role.PermissionCache
+
+// InitPermissionCache sets the cache RequirePermission resolves role permissions from.
+func InitPermissionCache(cache *role.PermissionCache) {
+	permissionCache = cache
+}
+
+// RequirePermission only lets the request through when one of the roles in the
+// token claims has been granted permission, or, for client tokens, when the
+// token carries permission as a scope. Tokens a client obtained on behalf of a
+// user need both. It must run after JwtAuthorization.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		claims, ok := jwt.GetClaims(c)
+		if !ok {
+			return &response.FailedResponseMessage{
+				Message: "Missing token claims",
+				Status:  "failed",
+				Code:    fiber.StatusUnauthorized,
+				Errors:  nil,
+			}
+		}
+
+		if claims.IsClientToken() || claims.IsDelegated() {
+			if !hasScope(claims, permission) {
+				return &response.FailedResponseMessage{
+					Message: "Forbidden",
+					Status:  "failed",
+					Code:    fiber.StatusForbidden,
+					Errors:  "missing scope " + permission,
+				}
+			}
+			if claims.IsClientToken() {
+				return c.Next()
+			}
+		}
+
+		allowed, err := permissionCache.HasPermission(claims.Roles, permission)
+		if err != nil {
+			return &response.FailedResponseMessage{
+				Message: "Failed to resolve permissions",
+				Status:  "failed",
+				Code:    fiber.StatusInternalServerError,
+				Errors:  err.Error(),
+			}
+		}
+
+		if !allowed {
+			return &response.FailedResponseMessage{
+				Message: "Forbidden",
+				Status:  "failed",
+				Code:    fiber.StatusForbidden,
+				Errors:  "missing permission " + permission,
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireFirstPartyToken refuses tokens a client obtained on behalf of a user,
+// so that the account settings of the user are only managed by the user
+// themselves. It must run after JwtAuthorization.
+func RequireFirstPartyToken(c *fiber.Ctx) error {
+
+	claims, ok := jwt.GetClaims(c)
+	if !ok {
+		return &response.FailedResponseMessage{
+			Message: "Missing token claims",
+			Status:  "failed",
+			Code:    fiber.StatusUnauthorized,
+			Errors:  nil,
+		}
+	}
+
+	if claims.IsDelegated() {
+		return &response.FailedResponseMessage{
+			Message: "Forbidden",
+			Status:  "failed",
+			Code:    fiber.StatusForbidden,
+			Errors:  "this endpoint does not accept tokens issued to client " + claims.ClientID,
+		}
+	}
+
+	return c.Next()
+}
+
+func hasScope(claims *jwt.Claims, scope string) bool {
+	for _, granted := range claims.Scopes() {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogNotifier writes messages to the application log. It is meant for local
+// development, as the log then contains whatever secrets the messages carry.
+type LogNotifier struct{}
+
+func (LogNotifier) Send(message Message) error {
+	log.Printf("notification to %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// FileNotifier appends messages to a file, for local testing.
+type FileNotifier struct {
+	Path string
+}
+
+var fileMu sync.Mutex
+
+func (n FileNotifier) Send(message Message) error {
+
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	file, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC1123Z), message.To, message.Subject, message.Body)
+	return err
+}
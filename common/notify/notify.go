@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Message is a notification to a single recipient, typically by email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users.
+type Notifier interface {
+	Send(message Message) error
+}
+
+var (
+	mu       sync.RWMutex
+	notifier Notifier = LogNotifier{}
+)
+
+// InitNotifier configures the notifier from the environment and panics when
+// the configuration is invalid.
+func InitNotifier() {
+	configured, err := NewFromEnv()
+	if err != nil {
+		panic(err)
+	}
+	SetNotifier(configured)
+}
+
+// NewFromEnv returns the notifier selected by NOTIFIER:
+//
+//	log   writes messages to the application log, the default
+//	file  appends messages to NOTIFIER_FILE
+//	smtp  sends email through SMTP_HOST, see NewSMTPNotifierFromEnv
+func NewFromEnv() (Notifier, error) {
+	switch kind := strings.ToLower(os.Getenv("NOTIFIER")); kind {
+	case "", "log":
+		return LogNotifier{}, nil
+	case "file":
+		path := os.Getenv("NOTIFIER_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("NOTIFIER_FILE is required for the file notifier")
+		}
+		return FileNotifier{Path: path}, nil
+	case "smtp":
+		return NewSMTPNotifierFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown NOTIFIER %q", kind)
+	}
+}
+
+// SetNotifier replaces the notifier used by Send.
+func SetNotifier(n Notifier) {
+	mu.Lock()
+	defer mu.Unlock()
+	notifier = n
+}
+
+func GetNotifier() Notifier {
+	mu.RLock()
+	defer mu.RUnlock()
+	return notifier
+}
+
+// Send delivers message with the configured notifier.
+func Send(message Message) error {
+	return GetNotifier().Send(message)
+}
@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier sends messages as plain text email.
+type SMTPNotifier struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPNotifierFromEnv reads the SMTP settings:
+//
+//	SMTP_HOST      server host name, required
+//	SMTP_PORT      server port, default 587
+//	SMTP_USERNAME  user for PLAIN authentication, optional
+//	SMTP_PASSWORD  password for PLAIN authentication
+//	SMTP_FROM      sender address, required
+func NewSMTPNotifierFromEnv() (*SMTPNotifier, error) {
+
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil, fmt.Errorf("SMTP_HOST is required for the smtp notifier")
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required for the smtp notifier")
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	return &SMTPNotifier{
+		Addr:     net.JoinHostPort(host, port),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}, nil
+}
+
+// Send delivers message. net/smtp upgrades to TLS when the server offers
+// STARTTLS and refuses PLAIN authentication over an unencrypted connection
+// to anything but localhost.
+func (n *SMTPNotifier) Send(message Message) error {
+
+	if strings.ContainsAny(message.To, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", message.To)
+	}
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		host, _, _ := net.SplitHostPort(n.Addr)
+		auth = smtp.PlainAuth("", n.Username, n.Password, host)
+	}
+
+	body := strings.Join([]string{
+		"From: " + n.From,
+		"To: " + message.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", message.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		message.Body,
+	}, "\r\n")
+
+	return smtp.SendMail(n.Addr, auth, n.From, []string{message.To}, []byte(body))
+}
@@ -0,0 +1,113 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// prefixes is the number of 4 hex digit SHA-1 prefixes the list is indexed by.
+const prefixes = 1 << 16
+
+// BreachedList is a local copy of a breached password list, one upper case
+// SHA-1 hash per line optionally followed by ":count", sorted by hash, as in
+// the downloads of Have I Been Pwned. Blank lines are skipped. Only an index
+// of where each hash prefix starts is kept in memory; lookups read the block
+// of the prefix from disk.
+type BreachedList struct {
+	path string
+	// offsets[p] is where the lines with prefix p start, offsets[p+1] where
+	// they end.
+	offsets [prefixes + 1]int64
+}
+
+// OpenBreachedList indexes the list at path.
+func OpenBreachedList(path string) (*BreachedList, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list %s: %w", path, err)
+	}
+	defer file.Close()
+
+	list := &BreachedList{path: path}
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	next := 0
+	previous := ""
+	for lineNumber := 1; ; lineNumber++ {
+
+		line, err := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) != 0 {
+			hash, _, _ := bytes.Cut(trimmed, []byte(":"))
+			if len(hash) != sha1.Size*2 {
+				return nil, fmt.Errorf("breached password list %s: line %d is not a SHA-1 hash", path, lineNumber)
+			}
+
+			prefix, errParse := strconv.ParseUint(string(hash[:4]), 16, 16)
+			if errParse != nil {
+				return nil, fmt.Errorf("breached password list %s: line %d is not a SHA-1 hash", path, lineNumber)
+			}
+			upper := string(bytes.ToUpper(hash))
+			if upper < previous {
+				return nil, fmt.Errorf("breached password list %s is not sorted at line %d", path, lineNumber)
+			}
+			previous = upper
+
+			for ; next <= int(prefix); next++ {
+				list.offsets[next] = offset
+			}
+		}
+		offset += int64(len(line))
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read breached password list %s: %w", path, err)
+		}
+	}
+
+	for ; next <= prefixes; next++ {
+		list.offsets[next] = offset
+	}
+	return list, nil
+}
+
+// Contains reports whether password is on the list.
+func (l *BreachedList) Contains(password string) (bool, error) {
+
+	sum := sha1.Sum([]byte(password))
+	hash := []byte(hex.EncodeToString(sum[:]))
+
+	prefix := int(sum[0])<<8 | int(sum[1])
+	start, end := l.offsets[prefix], l.offsets[prefix+1]
+	if start == end {
+		return false, nil
+	}
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	block := make([]byte, end-start)
+	if _, err := file.ReadAt(block, start); err != nil {
+		return false, err
+	}
+
+	for _, line := range bytes.Split(block, []byte("\n")) {
+		candidate, _, _ := bytes.Cut(bytes.TrimSpace(line), []byte(":"))
+		if bytes.EqualFold(candidate, hash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,102 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func writeList(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBreachedList(t *testing.T) {
+
+	breached := []string{"password", "123456", "hunter2", "letmein", "Tr0ub4dor&3"}
+	var hashes []string
+	for _, password := range breached {
+		hashes = append(hashes, sha1Hex(password))
+	}
+	sort.Strings(hashes)
+
+	lines := make([]string, len(hashes))
+	for i, hash := range hashes {
+		lines[i] = hash + ":42"
+	}
+	// The first hash without a count, in lower case and with CRLF and blank
+	// lines around it, as hand-edited lists have.
+	lines[0] = "\r\n" + strings.ToLower(hashes[0]) + "\r\n"
+
+	list, err := OpenBreachedList(writeList(t, strings.Join(lines, "\n")+"\n\n"))
+	if err != nil {
+		t.Fatalf("OpenBreachedList: %v", err)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"123456", true},
+		{"hunter2", true},
+		{"letmein", true},
+		{"Tr0ub4dor&3", true},
+		{"Password", false},
+		{"correct horse battery staple", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		t.Run(test.password, func(t *testing.T) {
+			got, err := list.Contains(test.password)
+			if err != nil {
+				t.Fatalf("Contains: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("Contains(%q) = %v, want %v", test.password, got, test.want)
+			}
+		})
+	}
+}
+
+func TestOpenBreachedListRejects(t *testing.T) {
+
+	a, b := sha1Hex("a"), sha1Hex("b")
+	if a > b {
+		a, b = b, a
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"not a hash", a + "\nnot a hash\n"},
+		{"short hash", a[:39] + "\n"},
+		{"not hex", "ZZZZ" + a[4:] + "\n"},
+		{"unsorted", b + "\n" + a + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := OpenBreachedList(writeList(t, test.content)); err == nil {
+				t.Errorf("OpenBreachedList succeeded")
+			}
+		})
+	}
+
+	if _, err := OpenBreachedList(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Errorf("OpenBreachedList of a missing file succeeded")
+	}
+}
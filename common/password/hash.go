@@ -0,0 +1,231 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownHash is returned for stored hashes of an unsupported algorithm.
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Hasher hashes new passwords with one algorithm and set of parameters.
+// Hashes are PHC strings, or the modular crypt format for bcrypt, so they
+// carry what is needed to verify them.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Current reports whether encoded was produced by this hasher with its
+	// present parameters.
+	Current(encoded string) bool
+	// MaxLength is the longest password in bytes the hasher takes in full.
+	MaxLength() int
+}
+
+// Argon2id is the preferred hasher. Memory is in KiB.
+type Argon2id struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	// argon2MaxLength only bounds the work a single login can cause; argon2id
+	// itself takes passwords of any length.
+	argon2MaxLength = 256
+	// bcryptMaxLength is where bcrypt stops reading the password.
+	bcryptMaxLength = 72
+)
+
+var phcEncoding = base64.RawStdEncoding
+
+func (h Argon2id) Hash(password string) (string, error) {
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2id) Current(encoded string) bool {
+	params, _, key, err := parseArgon2id(encoded)
+	return err == nil && params == h && len(key) == argon2KeyLength
+}
+
+func (h Argon2id) MaxLength() int {
+	return argon2MaxLength
+}
+
+// Bcrypt is kept for hashes created before argon2id was introduced, and for
+// deployments that prefer it.
+type Bcrypt struct {
+	Cost int
+}
+
+func (h Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(hash), err
+}
+
+func (h Bcrypt) Current(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err == nil && cost == h.Cost
+}
+
+func (h Bcrypt) MaxLength() int {
+	return bcryptMaxLength
+}
+
+// LoadHasherFromEnv reads the hashing settings:
+//
+//	PASSWORD_HASH                  argon2id or bcrypt, default argon2id
+//	PASSWORD_ARGON2_MEMORY         argon2id memory in KiB, default 65536
+//	PASSWORD_ARGON2_ITERATIONS     argon2id passes, default 3
+//	PASSWORD_ARGON2_PARALLELISM    argon2id lanes, default 4
+//	PASSWORD_BCRYPT_COST           bcrypt cost, default 10
+func LoadHasherFromEnv() (Hasher, error) {
+
+	switch algorithm := os.Getenv("PASSWORD_HASH"); algorithm {
+	case "", "argon2id":
+		memory, err := uintFromEnv("PASSWORD_ARGON2_MEMORY", 64*1024, 8, 4*1024*1024)
+		if err != nil {
+			return nil, err
+		}
+		iterations, err := uintFromEnv("PASSWORD_ARGON2_ITERATIONS", 3, 1, 100)
+		if err != nil {
+			return nil, err
+		}
+		parallelism, err := uintFromEnv("PASSWORD_ARGON2_PARALLELISM", 4, 1, 255)
+		if err != nil {
+			return nil, err
+		}
+		if memory < 8*parallelism {
+			return nil, fmt.Errorf("PASSWORD_ARGON2_MEMORY must be at least 8 KiB per lane")
+		}
+		return Argon2id{Memory: uint32(memory), Iterations: uint32(iterations), Parallelism: uint8(parallelism)}, nil
+	case "bcrypt":
+		cost, err := uintFromEnv("PASSWORD_BCRYPT_COST", uint64(bcrypt.DefaultCost), uint64(bcrypt.MinCost), uint64(bcrypt.MaxCost))
+		if err != nil {
+			return nil, err
+		}
+		return Bcrypt{Cost: int(cost)}, nil
+	default:
+		return nil, fmt.Errorf("unknown PASSWORD_HASH %q", algorithm)
+	}
+}
+
+// Hash hashes password with the preferred hasher.
+func Hash(password string) (string, error) {
+	return currentHasher().Hash(password)
+}
+
+// Verify checks password against encoded, whichever supported algorithm
+// produced it. rehash is true when the password matched but encoded should be
+// replaced by a hash from the preferred hasher.
+func Verify(encoded, password string) (match bool, rehash bool, err error) {
+
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		match, err = verifyArgon2id(encoded, password)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err = bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		match = err == nil
+	default:
+		return false, false, ErrUnknownHash
+	}
+
+	if err != nil || !match {
+		return false, false, err
+	}
+	return true, !currentHasher().Current(encoded), nil
+}
+
+// VerifyUnknown spends as long as Verify on a hash from the preferred hasher,
+// so requests for unknown users cannot be told apart by their timing.
+func VerifyUnknown(password string) {
+	mu.RLock()
+	dummy := unknownHash
+	mu.RUnlock()
+	Verify(dummy, password)
+}
+
+func verifyArgon2id(encoded, password string) (bool, error) {
+
+	params, salt, key, err := parseArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+// parseArgon2id splits "$argon2id$v=19$m=...,t=...,p=...$salt$key".
+func parseArgon2id(encoded string) (Argon2id, []byte, []byte, error) {
+
+	invalid := fmt.Errorf("invalid argon2id hash")
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2id{}, nil, nil, invalid
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2id{}, nil, nil, invalid
+	}
+
+	var params Argon2id
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2id{}, nil, nil, invalid
+	}
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return Argon2id{}, nil, nil, invalid
+	}
+
+	salt, err := phcEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2id{}, nil, nil, invalid
+	}
+	key, err := phcEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2id{}, nil, nil, invalid
+	}
+
+	return params, salt, key, nil
+}
+
+func currentHasher() Hasher {
+	mu.RLock()
+	defer mu.RUnlock()
+	return hasher
+}
+
+func uintFromEnv(name string, fallback, min, max uint64) (uint64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || parsed < min || parsed > max {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,103 @@
+package password
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// referenceHash is the argon2id test vector of the reference implementation
+// for "password" with the salt "somesalt".
+const referenceHash = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+
+func TestParseArgon2id(t *testing.T) {
+
+	params, salt, key, err := parseArgon2id(referenceHash)
+	if err != nil {
+		t.Fatalf("parseArgon2id: %v", err)
+	}
+	if want := (Argon2id{Memory: 65536, Iterations: 2, Parallelism: 1}); params != want {
+		t.Errorf("params = %+v, want %+v", params, want)
+	}
+	if !bytes.Equal(salt, []byte("somesalt")) {
+		t.Errorf("salt = %q, want %q", salt, "somesalt")
+	}
+	if len(key) != argon2KeyLength {
+		t.Errorf("key is %d bytes, want %d", len(key), argon2KeyLength)
+	}
+
+	invalid := []struct {
+		name    string
+		encoded string
+	}{
+		{"argon2i", "$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"old version", "$argon2id$v=16$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"missing version", "$argon2id$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"zero iterations", "$argon2id$v=19$m=65536,t=0,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"zero parallelism", "$argon2id$v=19$m=65536,t=2,p=0$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"padded salt", "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ=$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"empty key", "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$"},
+		{"extra field", referenceHash + "$x"},
+	}
+	for _, test := range invalid {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, _, err := parseArgon2id(test.encoded); err == nil {
+				t.Errorf("parseArgon2id(%q) succeeded", test.encoded)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+
+	fast := Argon2id{Memory: 64, Iterations: 1, Parallelism: 1}
+	fastHash, err := fast.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	bcryptHash, err := Bcrypt{Cost: bcrypt.MinCost}.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	mu.Lock()
+	previous := hasher
+	hasher = fast
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		hasher = previous
+		mu.Unlock()
+	})
+
+	tests := []struct {
+		name     string
+		encoded  string
+		password string
+		match    bool
+		rehash   bool
+		err      error
+	}{
+		{"reference vector", referenceHash, "password", true, true, nil},
+		{"reference vector, wrong password", referenceHash, "Password", false, false, nil},
+		{"current parameters", fastHash, "correct horse", true, false, nil},
+		{"current parameters, wrong password", fastHash, "correct horse ", false, false, nil},
+		{"bcrypt", bcryptHash, "correct horse", true, true, nil},
+		{"bcrypt, wrong password", bcryptHash, "correct", false, false, nil},
+		{"unknown format", "plain", "plain", false, false, ErrUnknownHash},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match, rehash, err := Verify(test.encoded, test.password)
+			if match != test.match || rehash != test.rehash || !errors.Is(err, test.err) {
+				t.Errorf("Verify = %v, %v, %v, want %v, %v, %v", match, rehash, err, test.match, test.rehash, test.err)
+			}
+		})
+	}
+
+	if match, _, err := Verify("$argon2id$v=19$m=65536,t=0,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc", "password"); match || err == nil {
+		t.Errorf("Verify of a malformed hash = %v, %v, want false and an error", match, err)
+	}
+}
@@ -0,0 +1,190 @@
+package password
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Policy is the set of rules new passwords have to satisfy.
+type Policy struct {
+	MinLength int
+	// MaxLength is at most what the hasher takes in full, since bcrypt
+	// ignores everything after 72 bytes.
+	MaxLength int
+	// MinClasses is how many of lower case, upper case, digits and symbols a
+	// password has to mix.
+	MinClasses int
+	// History is how many previous passwords of a user may not be reused.
+	History int
+	// BreachedFile is the path of the breached password list, empty to skip
+	// the check.
+	BreachedFile string
+}
+
+var (
+	mu       sync.RWMutex
+	current  Policy
+	breached *BreachedList
+	hasher   Hasher = Argon2id{Memory: 64 * 1024, Iterations: 3, Parallelism: 4}
+	// unknownHash is verified against for users that do not exist.
+	unknownHash string
+)
+
+// InitPasswordPolicy loads the policy and panics when it is invalid.
+func InitPasswordPolicy() {
+	if err := Reload(); err != nil {
+		panic(err)
+	}
+}
+
+// Reload re-reads the policy and the hashing settings from the environment
+// and re-indexes the breached password list.
+func Reload() error {
+
+	preferred, err := LoadHasherFromEnv()
+	if err != nil {
+		return err
+	}
+
+	policy, err := LoadPolicyFromEnv(preferred)
+	if err != nil {
+		return err
+	}
+	dummy, err := preferred.Hash("unknown user")
+	if err != nil {
+		return err
+	}
+
+	var list *BreachedList
+	if policy.BreachedFile != "" {
+		if list, err = OpenBreachedList(policy.BreachedFile); err != nil {
+			return err
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	current = policy
+	breached = list
+	hasher = preferred
+	unknownHash = dummy
+	return nil
+}
+
+// LoadPolicyFromEnv reads the password policy for passwords hashed by hasher:
+//
+//	PASSWORD_MIN_LENGTH     minimum length in characters, default 12
+//	PASSWORD_MAX_LENGTH     maximum length in bytes, at most and default 256 with argon2id and 72 with bcrypt
+//	PASSWORD_MIN_CLASSES    character classes to mix, default 3
+//	PASSWORD_HISTORY        previous passwords that may not be reused, default 5
+//	PASSWORD_BREACHED_FILE  sorted "SHA1[:count]" list of breached passwords, optional
+func LoadPolicyFromEnv(hasher Hasher) (Policy, error) {
+
+	policy := Policy{
+		MinLength:    12,
+		MaxLength:    hasher.MaxLength(),
+		MinClasses:   3,
+		History:      5,
+		BreachedFile: os.Getenv("PASSWORD_BREACHED_FILE"),
+	}
+
+	settings := []struct {
+		name  string
+		value *int
+		max   int
+	}{
+		{"PASSWORD_MIN_LENGTH", &policy.MinLength, hasher.MaxLength()},
+		{"PASSWORD_MAX_LENGTH", &policy.MaxLength, hasher.MaxLength()},
+		{"PASSWORD_MIN_CLASSES", &policy.MinClasses, 4},
+		{"PASSWORD_HISTORY", &policy.History, 100},
+	}
+	for _, setting := range settings {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > setting.max {
+			return Policy{}, fmt.Errorf("invalid %s %q", setting.name, value)
+		}
+		*setting.value = parsed
+	}
+
+	if policy.MinLength > policy.MaxLength {
+		return Policy{}, fmt.Errorf("PASSWORD_MIN_LENGTH %d exceeds PASSWORD_MAX_LENGTH %d", policy.MinLength, policy.MaxLength)
+	}
+
+	return policy, nil
+}
+
+// Current returns the active policy.
+func Current() Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Validate returns the rules password breaks, in the order they are listed in
+// the policy. It does not check the history, which is kept by the caller.
+func Validate(password, username string) ([]string, error) {
+
+	mu.RLock()
+	policy, list := current, breached
+	mu.RUnlock()
+
+	var violations []string
+
+	if length := len([]rune(password)); length < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("password must be at least %d characters long", policy.MinLength))
+	}
+	if len(password) > policy.MaxLength {
+		violations = append(violations, fmt.Sprintf("password must be at most %d bytes long", policy.MaxLength))
+	}
+	if classes := characterClasses(password); classes < policy.MinClasses {
+		violations = append(violations, fmt.Sprintf("password must mix at least %d of lower case letters, upper case letters, digits and symbols", policy.MinClasses))
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		violations = append(violations, "password must not contain the username")
+	}
+
+	if list != nil {
+		found, err := list.Contains(password)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			violations = append(violations, "password appears in a list of breached passwords")
+		}
+	}
+
+	return violations, nil
+}
+
+func characterClasses(password string) int {
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	return classes
+}
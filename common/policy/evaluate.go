@@ -0,0 +1,178 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	DecisionAllow         = "allow"
+	DecisionDeny          = "deny"
+	DecisionNotApplicable = "not_applicable"
+)
+
+type Decision struct {
+	Decision string `json:"decision"`
+	Rule     string `json:"rule,omitempty"`
+}
+
+// Denied reports whether a deny rule matched the request.
+func (d Decision) Denied() bool {
+	return d.Decision == DecisionDeny
+}
+
+type operator func(left, right interface{}) bool
+
+var operators = map[string]operator{
+	"eq":       equal,
+	"ne":       func(left, right interface{}) bool { return !equal(left, right) },
+	"in":       func(left, right interface{}) bool { return contains(right, left) },
+	"not_in":   func(left, right interface{}) bool { return !contains(right, left) },
+	"contains": contains,
+	"gt":       compare(func(a, b float64) bool { return a > b }),
+	"gte":      compare(func(a, b float64) bool { return a >= b }),
+	"lt":       compare(func(a, b float64) bool { return a < b }),
+	"lte":      compare(func(a, b float64) bool { return a <= b }),
+	"exists":   func(left, right interface{}) bool { return left != nil },
+}
+
+// Evaluate returns the decision for request. Deny rules override allow rules.
+func (e *Engine) Evaluate(request Request) Decision {
+
+	decision := Decision{Decision: DecisionNotApplicable}
+
+	for _, rule := range e.Rules() {
+		if !rule.targets(request.Action, request.Resource.Type) {
+			continue
+		}
+		if rule.Condition != nil && !rule.Condition.holds(request) {
+			continue
+		}
+		if rule.Effect == EffectDeny {
+			return Decision{Decision: DecisionDeny, Rule: rule.Name}
+		}
+		if decision.Decision == DecisionNotApplicable {
+			decision = Decision{Decision: DecisionAllow, Rule: rule.Name}
+		}
+	}
+
+	return decision
+}
+
+func (c Condition) holds(request Request) bool {
+
+	for _, nested := range c.All {
+		if !nested.holds(request) {
+			return false
+		}
+	}
+
+	if len(c.Any) != 0 {
+		matched := false
+		for _, nested := range c.Any {
+			if nested.holds(request) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if c.Not != nil && c.Not.holds(request) {
+		return false
+	}
+
+	if c.Attribute == "" {
+		return true
+	}
+
+	right := c.Value
+	if c.ValueFrom != "" {
+		right = request.lookup(c.ValueFrom)
+	}
+	return operators[c.Operator](request.lookup(c.Attribute), right)
+}
+
+// lookup resolves "subject.x", "resource.x" and "context.x". "resource.type"
+// is the resource type.
+func (r Request) lookup(name string) interface{} {
+
+	scope, attribute, _ := strings.Cut(name, ".")
+	switch scope {
+	case "subject":
+		return r.Subject[attribute]
+	case "resource":
+		if attribute == "type" {
+			return r.Resource.Type
+		}
+		return r.Resource.Attributes[attribute]
+	case "context":
+		return r.Context[attribute]
+	case "action":
+		return r.Action
+	}
+	return nil
+}
+
+func equal(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	if a, ok := toFloat(left); ok {
+		if b, ok := toFloat(right); ok {
+			return a == b
+		}
+	}
+	return fmt.Sprint(left) == fmt.Sprint(right)
+}
+
+// contains reports whether the list collection holds value. A string
+// collection is searched for value as a substring.
+func contains(collection, value interface{}) bool {
+
+	if s, ok := collection.(string); ok {
+		v, ok := value.(string)
+		return ok && strings.Contains(s, v)
+	}
+
+	list := reflect.ValueOf(collection)
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return false
+	}
+	for i := 0; i < list.Len(); i++ {
+		if equal(list.Index(i).Interface(), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compare(check func(a, b float64) bool) operator {
+	return func(left, right interface{}) bool {
+		a, ok := toFloat(left)
+		if !ok {
+			return false
+		}
+		b, ok := toFloat(right)
+		if !ok {
+			return false
+		}
+		return check(a, b)
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,89 @@
+package policy
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+
+	engine := &Engine{}
+	engine.SetRules([]Rule{
+		{
+			Name:      "admins",
+			Effect:    EffectAllow,
+			Actions:   []string{"*"},
+			Condition: &Condition{Attribute: "subject.roles", Operator: "contains", Value: 1},
+		},
+		{
+			Name:      "same-department",
+			Effect:    EffectAllow,
+			Actions:   []string{"user:*"},
+			Resources: []string{"user"},
+			Condition: &Condition{Attribute: "subject.department", Operator: "eq", ValueFrom: "resource.department"},
+		},
+		{
+			Name:    "office-hours",
+			Effect:  EffectDeny,
+			Actions: []string{"user:update", "user:delete"},
+			Condition: &Condition{Any: []Condition{
+				{Attribute: "context.hour", Operator: "lt", Value: 8},
+				{Attribute: "context.hour", Operator: "gte", Value: 18},
+			}},
+		},
+		{
+			Name:      "suspended",
+			Effect:    EffectDeny,
+			Actions:   []string{"*"},
+			Condition: &Condition{Attribute: "subject.status", Operator: "eq", Value: "suspended"},
+		},
+		{
+			Name:      "own-profile",
+			Effect:    EffectAllow,
+			Actions:   []string{"user:read"},
+			Resources: []string{"user"},
+			Condition: &Condition{Attribute: "subject.sub", Operator: "eq", ValueFrom: "resource.id"},
+		},
+	})
+
+	admin := Attributes{"sub": "1", "roles": []uint{1}, "department": "it"}
+	sales := Attributes{"sub": "2", "roles": []uint{3}, "department": "sales"}
+	suspended := Attributes{"sub": "3", "roles": []uint{1}, "department": "sales", "status": "suspended"}
+
+	salesUser := Resource{Type: "user", Attributes: Attributes{"id": 4, "department": "sales"}}
+	ownProfile := Resource{Type: "user", Attributes: Attributes{"id": 2, "department": "it"}}
+	daytime := Attributes{"hour": 10}
+	night := Attributes{"hour": 23}
+
+	tests := []struct {
+		name     string
+		request  Request
+		decision string
+		rule     string
+	}{
+		{"admin", Request{admin, "role:delete", Resource{Type: "role"}, daytime}, DecisionAllow, "admins"},
+		{"first allow rule wins", Request{admin, "user:read", Resource{Type: "user", Attributes: Attributes{"department": "it"}}, daytime}, DecisionAllow, "admins"},
+		{"same department", Request{sales, "user:update", salesUser, daytime}, DecisionAllow, "same-department"},
+		{"other department", Request{sales, "user:update", Resource{Type: "user", Attributes: Attributes{"department": "it"}}, daytime}, DecisionNotApplicable, ""},
+		{"other resource type", Request{sales, "user:update", Resource{Type: "role", Attributes: Attributes{"department": "sales"}}, daytime}, DecisionNotApplicable, ""},
+		{"deny after allow overrides it", Request{sales, "user:update", salesUser, night}, DecisionDeny, "office-hours"},
+		{"deny overrides an earlier admin allow", Request{admin, "user:delete", salesUser, night}, DecisionDeny, "office-hours"},
+		{"deny targets only its actions", Request{sales, "user:read", salesUser, night}, DecisionAllow, "same-department"},
+		{"deny before allow overrides it", Request{suspended, "user:read", salesUser, daytime}, DecisionDeny, "suspended"},
+		{"value from numeric attribute", Request{sales, "user:read", ownProfile, daytime}, DecisionAllow, "own-profile"},
+		{"missing attributes", Request{Attributes{}, "user:read", salesUser, Attributes{}}, DecisionNotApplicable, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision := engine.Evaluate(test.request)
+			if decision.Decision != test.decision || decision.Rule != test.rule {
+				t.Errorf("Evaluate = %s by %q, want %s by %q", decision.Decision, decision.Rule, test.decision, test.rule)
+			}
+			if decision.Denied() != (test.decision == DecisionDeny) {
+				t.Errorf("Denied = %v for %s", decision.Denied(), decision.Decision)
+			}
+		})
+	}
+
+	engine.SetRules(nil)
+	if decision := engine.Evaluate(Request{admin, "user:read", salesUser, daytime}); decision.Decision != DecisionNotApplicable {
+		t.Errorf("Evaluate without rules = %s, want %s", decision.Decision, DecisionNotApplicable)
+	}
+}
@@ -0,0 +1,189 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+type (
+	// Attributes describe a subject, resource or request context. Rules refer
+	// to them as "subject.<name>", "resource.<name>" and "context.<name>".
+	Attributes map[string]interface{}
+
+	Resource struct {
+		Type       string     `json:"type" yaml:"type"`
+		Attributes Attributes `json:"attributes" yaml:"attributes"`
+	}
+
+	Request struct {
+		Subject  Attributes
+		Action   string
+		Resource Resource
+		Context  Attributes
+	}
+
+	// Rule applies its effect to the actions and resource types it targets when
+	// its condition holds. Both target lists accept "*" and prefixes such as "user:*".
+	Rule struct {
+		Name        string     `json:"name" yaml:"name"`
+		Description string     `json:"description" yaml:"description"`
+		Effect      string     `json:"effect" yaml:"effect"`
+		Actions     []string   `json:"actions" yaml:"actions"`
+		Resources   []string   `json:"resources" yaml:"resources"`
+		Condition   *Condition `json:"condition" yaml:"condition"`
+	}
+
+	// Condition is either a comparison of Attribute with Value (or with the
+	// attribute named by ValueFrom), or a combination of nested conditions.
+	Condition struct {
+		All       []Condition `json:"all" yaml:"all"`
+		Any       []Condition `json:"any" yaml:"any"`
+		Not       *Condition  `json:"not" yaml:"not"`
+		Attribute string      `json:"attribute" yaml:"attribute"`
+		Operator  string      `json:"operator" yaml:"operator"`
+		Value     interface{} `json:"value" yaml:"value"`
+		ValueFrom string      `json:"value_from" yaml:"value_from"`
+	}
+
+	Document struct {
+		Rules []Rule `json:"rules" yaml:"rules"`
+	}
+)
+
+// Engine evaluates requests against a set of rules. A matching deny rule
+// overrides any allow rule; a request no rule matches is not applicable and
+// left to the permission checks on the route.
+type Engine struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+var engine = &Engine{}
+
+// InitPolicy loads the rules from the file named by POLICY_FILE. Without a
+// policy file every decision is not applicable.
+func InitPolicy() {
+	if err := Reload(); err != nil {
+		panic(err)
+	}
+}
+
+// Reload re-reads POLICY_FILE and replaces the active rules.
+func Reload() error {
+
+	path := os.Getenv("POLICY_FILE")
+	if path == "" {
+		engine.SetRules(nil)
+		return nil
+	}
+
+	rules, err := LoadFile(path)
+	if err != nil {
+		return err
+	}
+	engine.SetRules(rules)
+	return nil
+}
+
+func GetEngine() *Engine {
+	return engine
+}
+
+// LoadFile reads a YAML or JSON policy document.
+func LoadFile(path string) ([]Rule, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %s: %w", path, err)
+	}
+
+	// YAML is a superset of JSON, so one decoder handles both formats.
+	var document Document
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", filepath.Base(path), err)
+	}
+
+	for i, rule := range document.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("policy rule %d (%s): %w", i, rule.Name, err)
+		}
+	}
+
+	return document.Rules, nil
+}
+
+func (r Rule) validate() error {
+	if r.Effect != EffectAllow && r.Effect != EffectDeny {
+		return fmt.Errorf("effect must be %q or %q", EffectAllow, EffectDeny)
+	}
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("no actions")
+	}
+	if r.Condition != nil {
+		return r.Condition.validate()
+	}
+	return nil
+}
+
+func (c Condition) validate() error {
+
+	for _, nested := range append(append([]Condition{}, c.All...), c.Any...) {
+		if err := nested.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Not != nil {
+		if err := c.Not.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Attribute == "" {
+		if c.Operator != "" {
+			return fmt.Errorf("operator %s without attribute", c.Operator)
+		}
+		return nil
+	}
+	if _, ok := operators[c.Operator]; !ok {
+		return fmt.Errorf("unknown operator %q", c.Operator)
+	}
+	return nil
+}
+
+func (e *Engine) SetRules(rules []Rule) {
+	e.mu.Lock()
+	e.rules = rules
+	e.mu.Unlock()
+}
+
+func (e *Engine) Rules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.rules
+}
+
+func (r Rule) targets(action, resourceType string) bool {
+	return matchAny(r.Actions, action) && (len(r.Resources) == 0 || matchAny(r.Resources, resourceType))
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == value {
+			return true
+		}
+		if strings.HasSuffix(pattern, "*") && strings.HasPrefix(value, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
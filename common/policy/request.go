@@ -0,0 +1,71 @@
+package policy
+
+import (
+	"go-jwt/common/response"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const subjectLocalsKey = "policy_subject"
+
+// SetSubject stores the attributes of the authenticated caller.
+func SetSubject(c *fiber.Ctx, subject Attributes) {
+	c.Locals(subjectLocalsKey, subject)
+}
+
+// GetSubject returns the attributes stored by SetSubject, or empty attributes
+// for anonymous requests.
+func GetSubject(c *fiber.Ctx) Attributes {
+	if subject, ok := c.Locals(subjectLocalsKey).(Attributes); ok {
+		return subject
+	}
+	return Attributes{}
+}
+
+// RequestContext describes the request being made. Time attributes use the
+// zone named by POLICY_TIMEZONE, the server's local zone by default.
+func RequestContext(c *fiber.Ctx) Attributes {
+
+	now := time.Now()
+	if zone := os.Getenv("POLICY_TIMEZONE"); zone != "" {
+		if location, err := time.LoadLocation(zone); err == nil {
+			now = now.In(location)
+		}
+	}
+
+	return Attributes{
+		"ip":      c.IP(),
+		"method":  c.Method(),
+		"path":    c.Path(),
+		"time":    now.Format(time.RFC3339),
+		"hour":    now.Hour(),
+		"minute":  now.Minute(),
+		"weekday": strings.ToLower(now.Weekday().String()),
+	}
+}
+
+// Authorize evaluates action on resource for the caller of c and returns a 403
+// response when a deny rule matches.
+func Authorize(c *fiber.Ctx, action string, resource Resource) error {
+
+	decision := engine.Evaluate(Request{
+		Subject:  GetSubject(c),
+		Action:   action,
+		Resource: resource,
+		Context:  RequestContext(c),
+	})
+
+	if decision.Denied() {
+		return &response.FailedResponseMessage{
+			Message: "Forbidden",
+			Status:  "failed",
+			Code:    fiber.StatusForbidden,
+			Errors:  "denied by policy rule " + decision.Rule,
+		}
+	}
+
+	return nil
+}
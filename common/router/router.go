@@ -1,7 +1,12 @@
 package router
 
 import (
+	"go-jwt/common/middleware"
 	"go-jwt/modules/auth"
+	"go-jwt/modules/authz"
+	"go-jwt/modules/client"
+	"go-jwt/modules/discovery"
+	"go-jwt/modules/key"
 	"go-jwt/modules/role"
 	"go-jwt/modules/user"
 
@@ -16,22 +21,90 @@ func InitRouterPrivate(db *gorm.DB, c *fiber.App) *fiber.App {
 	// Role ROUTER API
 	roleRoute := api.Group("/role")
 	roleRepository := role.NewRepository(db)
-	roleService := role.NewService(roleRepository)
-	roleHandler := role.NewHandler(roleService)
-	roleRoute.Post("/create", roleHandler.Create)
-	roleRoute.Post("/search", roleHandler.FindRoles)
-	roleRoute.Get("/:name", roleHandler.FindOneRoleByName)
-	roleRoute.Get("/:id", roleHandler.FindOneRoleByID)
-	roleRoute.Patch("/:id", roleHandler.Update)
-	roleRoute.Delete("/:id", roleHandler.SoftDelete)
-	roleRoute.Put("/:id", roleHandler.RestoreSoftDelete)
+	permissionRepository := role.NewPermissionRepository(db)
+	permissionCache := role.NewPermissionCache(permissionRepository)
+	middleware.InitPermissionCache(permissionCache)
+	roleService := role.NewService(roleRepository, permissionCache)
+	permissionService := role.NewPermissionService(permissionRepository, permissionCache)
+	roleHandler := role.NewHandler(roleService, permissionService)
+	roleRoute.Post("/create", middleware.RequirePermission(role.PermissionRoleCreate), roleHandler.Create)
+	roleRoute.Post("/search", middleware.RequirePermission(role.PermissionRoleRead), roleHandler.FindRoles)
+	roleRoute.Get("/:name", middleware.RequirePermission(role.PermissionRoleRead), roleHandler.FindOneRoleByName)
+	roleRoute.Get("/:id", middleware.RequirePermission(role.PermissionRoleRead), roleHandler.FindOneRoleByID)
+	roleRoute.Patch("/:id", middleware.RequirePermission(role.PermissionRoleUpdate), roleHandler.Update)
+	roleRoute.Delete("/:id", middleware.RequirePermission(role.PermissionRoleDelete), roleHandler.SoftDelete)
+	roleRoute.Put("/:id", middleware.RequirePermission(role.PermissionRoleUpdate), roleHandler.RestoreSoftDelete)
+	roleRoute.Get("/:id/permissions", middleware.RequirePermission(role.PermissionRoleRead), roleHandler.FindRolePermissions)
+	roleRoute.Get("/:id/effective-permissions", middleware.RequirePermission(role.PermissionRoleRead), roleHandler.FindRoleEffectivePermissions)
+	roleRoute.Post("/:id/permissions", middleware.RequirePermission(role.PermissionRoleGrant), roleHandler.GrantPermissions)
+	roleRoute.Delete("/:id/permissions", middleware.RequirePermission(role.PermissionRoleGrant), roleHandler.RevokePermissions)
+
+	// PERMISSION ROUTER API
+	permissionRoute := api.Group("/permission")
+	permissionRoute.Post("/create", middleware.RequirePermission(role.PermissionPermCreate), roleHandler.CreatePermission)
+	permissionRoute.Post("/search", middleware.RequirePermission(role.PermissionPermRead), roleHandler.FindPermissions)
+	permissionRoute.Get("/:id", middleware.RequirePermission(role.PermissionPermRead), roleHandler.FindOnePermissionByID)
+	permissionRoute.Patch("/:id", middleware.RequirePermission(role.PermissionPermUpdate), roleHandler.UpdatePermission)
+	permissionRoute.Delete("/:id", middleware.RequirePermission(role.PermissionPermDelete), roleHandler.SoftDeletePermission)
 
 	// USER ROUTER API
 	userRoute := api.Group("/user")
 	userRepository := user.NewRepository(db)
 	userService := user.NewService(userRepository, roleRepository)
 	userHandler := user.NewHandler(userService)
-	userRoute.Post("/create", userHandler.Create)
+	userRoute.Post("/create", middleware.RequirePermission(role.PermissionUserCreate), userHandler.Create)
+	userRoute.Patch("/:id", middleware.RequirePermission(role.PermissionUserUpdate), userHandler.Update)
+	userRoute.Get("/:id/roles", middleware.RequirePermission(role.PermissionUserRead), userHandler.FindRoles)
+	userRoute.Post("/:id/roles", middleware.RequirePermission(role.PermissionUserAssign), userHandler.AssignRoles)
+	userRoute.Delete("/:id/roles", middleware.RequirePermission(role.PermissionUserAssign), userHandler.UnassignRoles)
+
+	authRepository := auth.NewRepository(db)
+	clientRepository := client.NewRepository(db)
+	mfaRepository := auth.NewMFARepository(db)
+	authService := auth.NewService(userRepository, roleRepository, authRepository, clientRepository, mfaRepository)
+	mfaService := auth.NewMFAService(mfaRepository, userRepository, authRepository)
+	authHandler := auth.NewHandler(authService, mfaService)
+	userRoute.Post("/:id/revoke-tokens", middleware.RequirePermission(role.PermissionTokenRevoke), authHandler.RevokeUserTokens)
+	userRoute.Post("/:id/unlock", middleware.RequirePermission(role.PermissionUserUnlock), authHandler.Unlock)
+	userRoute.Get("/:id/sessions", middleware.RequirePermission(role.PermissionUserRead), authHandler.ListUserSessions)
+	userRoute.Delete("/:id/sessions/:sid", middleware.RequirePermission(role.PermissionTokenRevoke), authHandler.RevokeUserSession)
+
+	// KEY ROUTER API
+	keyRoute := api.Group("/key")
+	keyHandler := key.NewHandler()
+	keyRoute.Get("/", middleware.RequirePermission(role.PermissionKeyRead), keyHandler.FindKeys)
+	keyRoute.Post("/rotate", middleware.RequirePermission(role.PermissionKeyRotate), keyHandler.Rotate)
+
+	// CLIENT ROUTER API
+	clientRoute := api.Group("/client")
+	clientService := client.NewService(clientRepository)
+	clientHandler := client.NewHandler(clientService)
+	clientRoute.Post("/create", middleware.RequirePermission(role.PermissionClientCreate), clientHandler.Create)
+	clientRoute.Post("/search", middleware.RequirePermission(role.PermissionClientRead), clientHandler.FindClients)
+	clientRoute.Get("/:id", middleware.RequirePermission(role.PermissionClientRead), clientHandler.FindOneClientByID)
+	clientRoute.Patch("/:id", middleware.RequirePermission(role.PermissionClientUpdate), clientHandler.Update)
+	clientRoute.Delete("/:id", middleware.RequirePermission(role.PermissionClientDelete), clientHandler.SoftDelete)
+	clientRoute.Post("/:id/rotate-secret", middleware.RequirePermission(role.PermissionClientUpdate), clientHandler.RotateSecret)
+
+	// ME ROUTER API
+	meRoute := api.Group("/me", middleware.RequireFirstPartyToken)
+	meRoute.Post("/mfa/totp", authHandler.EnrollTOTP)
+	meRoute.Get("/mfa/totp/qr", authHandler.TOTPQRCode)
+	meRoute.Post("/mfa/totp/confirm", authHandler.ConfirmTOTP)
+	meRoute.Delete("/mfa/totp", authHandler.DisableTOTP)
+	meRoute.Post("/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+	meRoute.Put("/password", authHandler.ChangePassword)
+	meRoute.Post("/webauthn/register", authHandler.BeginWebAuthnRegistration)
+	meRoute.Post("/webauthn/register/finish", authHandler.FinishWebAuthnRegistration)
+	meRoute.Get("/webauthn/credentials", authHandler.ListWebAuthnCredentials)
+	meRoute.Delete("/webauthn/credentials/:id", authHandler.DeleteWebAuthnCredential)
+	meRoute.Get("/sessions", authHandler.ListSessions)
+	meRoute.Delete("/sessions/:id", authHandler.RevokeSession)
+
+	// AUTHZ ROUTER API
+	authzRoute := api.Group("/authz")
+	authzHandler := authz.NewHandler()
+	authzRoute.Post("/check", middleware.RequirePermission(role.PermissionAuthzCheck), authzHandler.Check)
 
 	return c
 }
@@ -42,11 +115,45 @@ func InitRouterPublic(db *gorm.DB, c *fiber.App) *fiber.App {
 
 	roleRepository := role.NewRepository(db)
 	userRepository := user.NewRepository(db)
+	authRepository := auth.NewRepository(db)
+	clientRepository := client.NewRepository(db)
+	mfaRepository := auth.NewMFARepository(db)
 
-	authService := auth.NewService(userRepository, roleRepository)
-	authHandler := auth.NewHandler(authService)
+	authService := auth.NewService(userRepository, roleRepository, authRepository, clientRepository, mfaRepository)
+	mfaService := auth.NewMFAService(mfaRepository, userRepository, authRepository)
+	authHandler := auth.NewHandler(authService, mfaService)
 
+	api.Post("/signup", authHandler.Signup)
+	api.Post("/verify-email", authHandler.VerifyEmail)
+	api.Post("/verify-email/resend", authHandler.ResendVerification)
 	api.Post("/login", authHandler.Login)
+	api.Post("/login/mfa", authHandler.LoginMFA)
+	api.Post("/magic-link", authHandler.RequestMagicLink)
+	api.Post("/magic-link/login", authHandler.LoginMagicLink)
+	api.Post("/webauthn/login", authHandler.BeginWebAuthnLogin)
+	api.Post("/webauthn/login/finish", authHandler.FinishWebAuthnLogin)
+	api.Get("/authorize", authHandler.Authorize)
+	api.Post("/authorize", authHandler.Approve)
+	api.Post("/token", authHandler.Token)
+	api.Post("/password/forgot", authHandler.RequestPasswordReset)
+	api.Post("/password/reset", authHandler.ResetPassword)
+	api.Post("/refresh", authHandler.Refresh)
+	api.Post("/logout", middleware.JwtAuthorization, authHandler.Logout)
+	api.Post("/introspect", middleware.JwtAuthorization, middleware.RequirePermission(role.PermissionTokenIntrospect), authHandler.Introspect)
+	api.Get("/userinfo", middleware.JwtAuthorization, authHandler.UserInfo)
+	api.Post("/userinfo", middleware.JwtAuthorization, authHandler.UserInfo)
+
+	return c
+}
+
+func InitRouterWellKnown(c *fiber.App) *fiber.App {
+
+	api := c.Group("/.well-known")
+
+	discoveryHandler := discovery.NewHandler()
+
+	api.Get("/jwks.json", discoveryHandler.JWKS)
+	api.Get("/openid-configuration", discoveryHandler.OpenIDConfiguration)
 
 	return c
 }
@@ -0,0 +1,67 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 test vectors.
+var rfc6238Secret = []byte("12345678901234567890")
+
+// The vectors of RFC 6238 appendix B for SHA-1, cut to the last 6 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestGenerate(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		if code := generate(rfc6238Secret, vector.unix/Period); code != vector.code {
+			t.Errorf("generate at %d = %s, want %s", vector.unix, code, vector.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	secret := encoding.EncodeToString(rfc6238Secret)
+
+	for _, vector := range rfc6238Vectors {
+		step, ok := Validate(secret, vector.code, time.Unix(vector.unix, 0))
+		if !ok || step != vector.unix/Period {
+			t.Errorf("Validate at %d = %d, %v, want %d, true", vector.unix, step, ok, vector.unix/Period)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		unix   int64
+		step   int64
+		ok     bool
+	}{
+		{"lower case secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "005924", 1234567890, 1234567890 / Period, true},
+		{"previous period", secret, "005924", 1234567890 + Period, 1234567890 / Period, true},
+		{"next period", secret, "005924", 1234567890 - Period, 1234567890 / Period, true},
+		{"beyond skew", secret, "005924", 1234567890 + 2*Period, 0, false},
+		{"wrong code", secret, "005925", 1234567890, 0, false},
+		{"short code", secret, "05924", 1234567890, 0, false},
+		{"invalid secret", "not base32!", "005924", 1234567890, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, ok := Validate(test.secret, test.code, time.Unix(test.unix, 0))
+			if step != test.step || ok != test.ok {
+				t.Errorf("Validate = %d, %v, want %d, %v", step, ok, test.step, test.ok)
+			}
+		})
+	}
+}
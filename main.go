@@ -3,9 +3,17 @@ package main
 import (
 	"fmt"
 	"go-jwt/common/database"
+	"go-jwt/common/jwt"
 	"go-jwt/common/middleware"
+	"go-jwt/common/notify"
+	"go-jwt/common/password"
+	"go-jwt/common/policy"
 	"go-jwt/common/response"
 	"go-jwt/common/router"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/goccy/go-json"
 
@@ -21,9 +29,38 @@ func catch() {
 	}
 }
 
+// reloadOnSignal rotates the JWT signing key and reloads the authorization
+// and password policies whenever the process receives SIGHUP.
+func reloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		if err := jwt.ReloadKeys(); err != nil {
+			log.Printf("failed to reload JWT keys: %v", err)
+		} else {
+			log.Printf("JWT keys reloaded, active key %s", jwt.GetKeyring().Active().ID)
+		}
+		if err := policy.Reload(); err != nil {
+			log.Printf("failed to reload policy: %v", err)
+		} else {
+			log.Printf("policy reloaded, %d rules", len(policy.GetEngine().Rules()))
+		}
+		if err := password.Reload(); err != nil {
+			log.Printf("failed to reload password policy: %v", err)
+		} else {
+			log.Printf("password policy reloaded")
+		}
+	}
+}
+
 func main() {
 	defer catch()
 	db := database.InitDB()
+	jwt.InitJWT(db)
+	policy.InitPolicy()
+	password.InitPasswordPolicy()
+	notify.InitNotifier()
+	go reloadOnSignal()
 	app := fiber.New(fiber.Config{
 		JSONEncoder: json.Marshal,
 		JSONDecoder: json.Unmarshal,
@@ -33,6 +70,7 @@ func main() {
 	}))
 	app.Use(middleware.LoggerMiddleware)
 	app.Use(middleware.HandlingErrorMiddleware)
+	app = router.InitRouterWellKnown(app)
 	app = router.InitRouterPublic(db, app)
 	app.Use(middleware.JwtAuthorization)
 	app = router.InitRouterPrivate(db, app)
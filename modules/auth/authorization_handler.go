@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"go-jwt/common/response"
+	"html/template"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// authorizeCSRFCookie holds the nonce the login form token is derived from.
+const authorizeCSRFCookie = "authorize_csrf"
+
+var authorizePage = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in</title>
+<style>
+body { font-family: sans-serif; max-width: 22rem; margin: 4rem auto; padding: 0 1rem; }
+label, input, button { display: block; width: 100%; box-sizing: border-box; margin-top: .5rem; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+{{if .Client}}
+<h1>Sign in to {{.Client}}</h1>
+{{if .Scopes}}<p>{{.Client}} is requesting access to:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<label>Username <input name="username" autocomplete="username" required autofocus></label>
+<label>Password <input name="password" type="password" autocomplete="current-password" required></label>
+<label>Authentication code, if enabled <input name="otp" autocomplete="one-time-code"></label>
+<button name="action" value="approve">Allow</button>
+<button name="action" value="deny" formnovalidate>Deny</button>
+</form>
+{{else}}
+<h1>Invalid request</h1>
+<p class="error">{{.Error}}</p>
+{{end}}
+</body>
+</html>
+`))
+
+type authorizePageData struct {
+	Client    string
+	Scopes    []string
+	Params    map[string]string
+	CSRFToken string
+	Error     string
+}
+
+// Authorize is the RFC 6749 authorization endpoint. It renders the login and
+// consent page for a valid request.
+func (h *handler) Authorize(c *fiber.Ctx) error {
+
+	var input AuthorizeInput
+	if err := c.QueryParser(&input); err != nil {
+		return renderAuthorizePage(c, fiber.StatusBadRequest, authorizePageData{Error: err.Error()})
+	}
+
+	registered, err := h.service.CheckAuthorizationRequest(input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return authorizationError(c, input, registered.ID != 0, err)
+	}
+
+	return renderLoginPage(c, fiber.StatusOK, registered.Name, input, "")
+}
+
+// Approve handles the login page. Successful sign-ins are redirected back to
+// the client with an authorization code; wrong credentials show the page again.
+func (h *handler) Approve(c *fiber.Ctx) error {
+
+	var input AuthorizeInput
+	if err := c.BodyParser(&input); err != nil {
+		return renderAuthorizePage(c, fiber.StatusBadRequest, authorizePageData{Error: err.Error()})
+	}
+
+	registered, err := h.service.CheckAuthorizationRequest(input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return authorizationError(c, input, registered.ID != 0, err)
+	}
+
+	// A form posted from another site, or rendered for another request, could
+	// sign the user in without them asking for it.
+	if !checkAuthorizeCSRF(c, input) {
+		return renderLoginPage(c, fiber.StatusForbidden, registered.Name, input, "The sign-in form has expired, please try again")
+	}
+
+	if input.Action == "deny" {
+		return authorizationError(c, input, true, response.FailedResponseMessage{
+			Message: "access_denied",
+			Errors:  "the user denied the request",
+		})
+	}
+
+	location, err := h.service.Authorize(input, c.IP(), c.Get(fiber.HeaderUserAgent))
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		if err.Code == fiber.StatusUnauthorized || err.Code == fiber.StatusTooManyRequests {
+			return renderLoginPage(c, err.Code, registered.Name, input, err.Message)
+		}
+		return authorizationError(c, input, true, err)
+	}
+
+	return c.Redirect(location, fiber.StatusFound)
+}
+
+// authorizationError sends the error back to the client when its redirect URI
+// has been verified, and shows it to the user otherwise.
+func authorizationError(c *fiber.Ctx, input AuthorizeInput, redirect bool, err response.FailedResponseMessage) error {
+
+	description, _ := err.Errors.(string)
+	if !redirect {
+		status := err.Code
+		if status == 0 {
+			status = fiber.StatusBadRequest
+		}
+		return renderAuthorizePage(c, status, authorizePageData{Error: description})
+	}
+
+	return c.Redirect(authorizationRedirect(input.RedirectURI, url.Values{
+		"error":             {err.Message},
+		"error_description": {description},
+		"state":             {input.State},
+	}), fiber.StatusFound)
+}
+
+// renderLoginPage shows the login form for input with a new CSRF token.
+func renderLoginPage(c *fiber.Ctx, status int, clientName string, input AuthorizeInput, message string) error {
+
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(nonce)
+
+	c.Cookie(&fiber.Cookie{
+		Name:     authorizeCSRFCookie,
+		Value:    encoded,
+		Path:     c.Path(),
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+
+	return renderAuthorizePage(c, status, authorizePageData{
+		Client:    clientName,
+		Scopes:    strings.Fields(input.Scope),
+		Params:    authorizeParams(input),
+		CSRFToken: authorizeCSRFToken(encoded, input),
+		Error:     message,
+	})
+}
+
+// checkAuthorizeCSRF reports whether the posted form was rendered for this
+// browser, which holds the nonce cookie, and for the same request parameters.
+func checkAuthorizeCSRF(c *fiber.Ctx, input AuthorizeInput) bool {
+	nonce := c.Cookies(authorizeCSRFCookie)
+	return nonce != "" && hmac.Equal([]byte(authorizeCSRFToken(nonce, input)), []byte(input.CSRFToken))
+}
+
+// authorizeCSRFToken is the HMAC of the authorization request parameters keyed
+// by the nonce of the cookie.
+func authorizeCSRFToken(nonce string, input AuthorizeInput) string {
+
+	params := authorizeParams(input)
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mac := hmac.New(sha256.New, []byte(nonce))
+	for _, name := range names {
+		mac.Write([]byte(name + "=" + url.QueryEscape(params[name]) + "&"))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func renderAuthorizePage(c *fiber.Ctx, status int, data authorizePageData) error {
+
+	var page bytes.Buffer
+	if err := authorizePage.Execute(&page, data); err != nil {
+		return err
+	}
+
+	// The page collects credentials, so it must not be framed or cached.
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderXFrameOptions, "DENY")
+	c.Set(fiber.HeaderContentSecurityPolicy, "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(status).Send(page.Bytes())
+}
+
+// authorizeParams are the request parameters the login page posts back.
+func authorizeParams(input AuthorizeInput) map[string]string {
+	return map[string]string{
+		"response_type":         input.ResponseType,
+		"client_id":             input.ClientID,
+		"redirect_uri":          input.RedirectURI,
+		"scope":                 input.Scope,
+		"state":                 input.State,
+		"code_challenge":        input.CodeChallenge,
+		"code_challenge_method": input.CodeChallengeMethod,
+		"nonce":                 input.Nonce,
+	}
+}
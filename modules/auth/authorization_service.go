@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"go-jwt/common/jwt"
+	"go-jwt/common/response"
+	"go-jwt/modules/client"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const defaultAuthorizationCodeTTL = time.Minute
+
+// CheckAuthorizationRequest validates an authorization request. Error messages
+// are the OAuth error codes. The client is only returned once its redirect
+// URI has been verified; a zero client means the error must be shown to the
+// user instead of being redirected.
+func (s *service) CheckAuthorizationRequest(input AuthorizeInput) (client.Client, response.FailedResponseMessage) {
+
+	registered, err := s.clientRepo.FindOneClientByClientID(input.ClientID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return client.Client{}, response.FailedResponseMessage{
+				Message: "invalid_client",
+				Status:  "failed",
+				Code:    http.StatusBadRequest,
+				Errors:  "unknown client",
+			}
+		}
+		return client.Client{}, response.FailedResponseMessage{
+			Message: "server_error",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	if input.RedirectURI == "" || !registered.AllowsRedirectURI(input.RedirectURI) {
+		return client.Client{}, response.FailedResponseMessage{
+			Message: "invalid_request",
+			Status:  "failed",
+			Code:    http.StatusBadRequest,
+			Errors:  "redirect_uri is not registered for this client",
+		}
+	}
+
+	if input.ResponseType != "code" {
+		return registered, response.FailedResponseMessage{
+			Message: "unsupported_response_type",
+			Status:  "failed",
+			Code:    http.StatusBadRequest,
+			Errors:  "only the code response type is supported",
+		}
+	}
+
+	// RFC 7636 code challenges are 43 to 128 characters. Plain challenges
+	// offer no protection when the request is intercepted, so only S256 is
+	// accepted.
+	if input.CodeChallengeMethod != "S256" || len(input.CodeChallenge) < 43 || len(input.CodeChallenge) > 128 {
+		return registered, response.FailedResponseMessage{
+			Message: "invalid_request",
+			Status:  "failed",
+			Code:    http.StatusBadRequest,
+			Errors:  "a code_challenge with code_challenge_method S256 is required",
+		}
+	}
+
+	if _, errScope := resolveScopes(registered, input.Scope); !reflect.DeepEqual(errScope, response.FailedResponseMessage{}) {
+		return registered, errScope
+	}
+
+	return registered, response.FailedResponseMessage{}
+}
+
+// Authorize authenticates the user on the login page and returns the redirect
+// URI carrying a new authorization code.
+func (s *service) Authorize(input AuthorizeInput, ip, userAgent string) (string, response.FailedResponseMessage) {
+
+	registered, errRequest := s.CheckAuthorizationRequest(input)
+	if !reflect.DeepEqual(errRequest, response.FailedResponseMessage{}) {
+		return "", errRequest
+	}
+
+	user, errAuth := s.authenticate(input.Username, input.Password, ip)
+	if !reflect.DeepEqual(errAuth, response.FailedResponseMessage{}) {
+		return "", errAuth
+	}
+
+	if errSecond := s.checkSecondFactor(user, input.OTP, ip); !reflect.DeepEqual(errSecond, response.FailedResponseMessage{}) {
+		return "", errSecond
+	}
+
+	scopes, _ := resolveScopes(registered, input.Scope)
+	audience, errAudience := resolveAudience(registered, "")
+	if !reflect.DeepEqual(errAudience, response.FailedResponseMessage{}) {
+		return "", errAudience
+	}
+
+	code, codeHash, err := newRefreshToken()
+	if err != nil {
+		return "", response.FailedResponseMessage{
+			Message: "server_error",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	if _, err := s.authRepo.SaveAuthorizationCode(AuthorizationCode{
+		CodeHash:      codeHash,
+		ClientID:      registered.ClientID,
+		UserID:        user.ID,
+		RedirectURI:   input.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		Audience:      audience,
+		CodeChallenge: input.CodeChallenge,
+		Nonce:         input.Nonce,
+		AuthTime:      time.Now(),
+		FamilyID:      uuid.NewString(),
+		IP:            ip,
+		UserAgent:     userAgent,
+		ExpiresAt:     time.Now().Add(authorizationCodeTTL()),
+	}); err != nil {
+		return "", response.FailedResponseMessage{
+			Message: "server_error",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	return authorizationRedirect(input.RedirectURI, url.Values{"code": {code}, "state": {input.State}}), response.FailedResponseMessage{}
+}
+
+// ExchangeAuthorizationCode implements the authorization code grant. Public
+// clients identify themselves with client_id alone; the code verifier proves
+// they started the flow.
+func (s *service) ExchangeAuthorizationCode(input TokenInput) (TokenResponse, response.FailedResponseMessage) {
+
+	registered, errClient := s.authenticateClient(input.ClientID, input.ClientSecret)
+	if !reflect.DeepEqual(errClient, response.FailedResponseMessage{}) {
+		return TokenResponse{}, errClient
+	}
+
+	code, err := s.authRepo.ConsumeAuthorizationCode(hashToken(input.Code))
+	if err != nil {
+		var responseErr *response.FailedResponseMessage
+		if errors.As(err, &responseErr) {
+			// The access tokens issued for a replayed code die with its session.
+			if code.UsedAt != nil {
+				if _, err := jwt.GetSessionStore().Revoke(code.UserID, code.FamilyID); err != nil {
+					return TokenResponse{}, response.FailedResponseMessage{
+						Message: "server_error",
+						Status:  "failed",
+						Code:    http.StatusInternalServerError,
+						Errors:  err.Error(),
+					}
+				}
+			}
+			return TokenResponse{}, *responseErr
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			return TokenResponse{}, response.FailedResponseMessage{
+				Message: "invalid_grant",
+				Status:  "failed",
+				Code:    http.StatusBadRequest,
+				Errors:  "invalid authorization code",
+			}
+		}
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "server_error",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	if code.ClientID != registered.ClientID || code.RedirectURI != input.RedirectURI || !verifyCodeChallenge(code.CodeChallenge, input.CodeVerifier) {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "invalid_grant",
+			Status:  "failed",
+			Code:    http.StatusBadRequest,
+			Errors:  "the authorization code was not issued for this client, redirect_uri or code_verifier",
+		}
+	}
+
+	user, err := s.userRepo.FindOneUserByID(code.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return TokenResponse{}, response.FailedResponseMessage{
+				Message: "invalid_grant",
+				Status:  "failed",
+				Code:    http.StatusBadRequest,
+				Errors:  "user no longer exists",
+			}
+		}
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "server_error",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	session, errSession := startSession(code.FamilyID, user.ID, code.ClientID, code.IP, code.UserAgent)
+	if !reflect.DeepEqual(errSession, response.FailedResponseMessage{}) {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "server_error",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  errSession.Errors,
+		}
+	}
+
+	claims := jwt.NewUserClaims(user.ID, user.Username, roleIDs(user.Roles), code.Audience)
+	claims.ClientID = code.ClientID
+	claims.Scope = code.Scope
+	claims.SessionID = session.ID
+	accessToken, err := jwt.GenerateToken(claims)
+	if err != nil {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "server_error",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	refreshToken, tokenHash, err := newRefreshToken()
+	if err != nil {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "server_error",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	if _, err := s.authRepo.SaveRefreshToken(RefreshToken{
+		FamilyID:  code.FamilyID,
+		UserID:    user.ID,
+		Audience:  code.Audience,
+		ClientID:  code.ClientID,
+		Scope:     code.Scope,
+		AuthTime:  code.AuthTime,
+		TokenHash: tokenHash,
+		ExpiresAt: session.ExpiresAt,
+	}); err != nil {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "server_error",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	token := buildTokenResponse(accessToken, claims, refreshToken)
+	token.Scope = claims.Scope
+
+	if contains(claims.Scopes(), ScopeOpenID) {
+		token.IDToken, err = jwt.GenerateIDToken(claims.Subject, code.ClientID, code.Nonce, code.AuthTime, accessToken)
+		if err != nil {
+			return TokenResponse{}, response.FailedResponseMessage{
+				Message: "server_error",
+				Status:  "failed",
+				Code:    http.StatusInternalServerError,
+				Errors:  err.Error(),
+			}
+		}
+	}
+
+	return token, response.FailedResponseMessage{}
+}
+
+// authorizationRedirect adds params to the query of redirectURI, leaving out
+// empty values.
+func authorizationRedirect(redirectURI string, params url.Values) string {
+
+	location, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := location.Query()
+	for name, values := range params {
+		for _, value := range values {
+			if value != "" {
+				query.Add(name, value)
+			}
+		}
+	}
+	location.RawQuery = query.Encode()
+	return location.String()
+}
+
+// verifyCodeChallenge checks the RFC 7636 S256 transformation of verifier.
+func verifyCodeChallenge(challenge, verifier string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return verifier != "" && subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func authorizationCodeTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("AUTHORIZATION_CODE_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultAuthorizationCodeTTL
+}
+
+// authenticateClient authenticates a client at the token endpoint. Public
+// clients identify themselves with client_id alone.
+func (s *service) authenticateClient(clientID, clientSecret string) (client.Client, response.FailedResponseMessage) {
+
+	registered, err := s.clientRepo.FindOneClientByClientID(clientID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return client.Client{}, response.FailedResponseMessage{
+			Message: "server_error",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	if err != nil || (!registered.Public && !registered.VerifySecret(clientSecret)) {
+		return client.Client{}, response.FailedResponseMessage{
+			Message: "invalid_client",
+			Status:  "failed",
+			Code:    http.StatusUnauthorized,
+			Errors:  "client authentication failed",
+		}
+	}
+	return registered, response.FailedResponseMessage{}
+}
@@ -0,0 +1,33 @@
+package auth
+
+import "testing"
+
+func TestVerifyCodeChallenge(t *testing.T) {
+
+	// The example of RFC 7636 appendix B.
+	const (
+		verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+
+	tests := []struct {
+		name      string
+		challenge string
+		verifier  string
+		want      bool
+	}{
+		{"rfc 7636 example", challenge, verifier, true},
+		{"wrong verifier", challenge, verifier + "x", false},
+		{"plain method", verifier, verifier, false},
+		{"padded challenge", challenge + "=", verifier, false},
+		{"empty verifier", "47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU", "", false},
+		{"empty challenge", "", verifier, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := verifyCodeChallenge(test.challenge, test.verifier); got != test.want {
+				t.Errorf("verifyCodeChallenge(%q, %q) = %v, want %v", test.challenge, test.verifier, got, test.want)
+			}
+		})
+	}
+}
@@ -1,18 +1,24 @@
 package auth
 
 import (
+	"encoding/base64"
+	"go-jwt/common/jwt"
 	"go-jwt/common/response"
+	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type handler struct {
-	service Service
+	service    Service
+	mfaService MFAService
 }
 
-func NewHandler(service Service) *handler {
-	return &handler{service: service}
+func NewHandler(service Service, mfaService MFAService) *handler {
+	return &handler{service: service, mfaService: mfaService}
 }
 
 func (h *handler) Login(c *fiber.Ctx) error {
@@ -38,12 +44,326 @@ func (h *handler) Login(c *fiber.Ctx) error {
 		}
 	}
 
-	token, err := h.service.Login(input.Username, input.Password)
+	token, challenge, err := h.service.Login(input.Username, input.Password, input.Audience, c.IP(), c.Get(fiber.HeaderUserAgent))
 	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
 		return &err
 	}
 
-	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("login successfully", 200, map[string]string{
-		"access_token": token,
-	}))
+	if challenge != nil {
+		return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("mfa required", 200, challenge))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("login successfully", 200, token))
+}
+
+func (h *handler) LoginMFA(c *fiber.Ctx) error {
+
+	var input MFALoginInput
+
+	if err := c.BodyParser(&input); err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Failed to parse request body",
+			Status:  "failed",
+			Code:    fiber.StatusUnprocessableEntity,
+			Errors:  err.Error(),
+		}
+	}
+
+	validation := response.ValidateBodyRequest(input)
+	if len(validation) != 0 {
+		return &response.FailedResponseMessage{
+			Message: "Failed request body",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  validation,
+		}
+	}
+
+	token, err := h.service.VerifyMFA(input.MFAToken, input.Code, c.IP(), c.Get(fiber.HeaderUserAgent))
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("login successfully", 200, token))
+}
+
+func (h *handler) Refresh(c *fiber.Ctx) error {
+
+	var input RefreshInput
+
+	if err := c.BodyParser(&input); err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Failed to parse request body",
+			Status:  "failed",
+			Code:    fiber.StatusUnprocessableEntity,
+			Errors:  err.Error(),
+		}
+	}
+
+	validation := response.ValidateBodyRequest(input)
+	if len(validation) != 0 {
+		return &response.FailedResponseMessage{
+			Message: "Failed request body",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  validation,
+		}
+	}
+
+	token, err := h.service.Refresh(input.RefreshToken, "", "")
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("token refreshed successfully", 200, token))
+}
+
+func (h *handler) Logout(c *fiber.Ctx) error {
+
+	var input LogoutInput
+
+	if len(c.Body()) != 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return &response.FailedResponseMessage{
+				Message: "Failed to parse request body",
+				Status:  "failed",
+				Code:    fiber.StatusUnprocessableEntity,
+				Errors:  err.Error(),
+			}
+		}
+	}
+
+	claims, ok := jwt.GetClaims(c)
+	if !ok {
+		return &response.FailedResponseMessage{
+			Message: "Missing token claims",
+			Status:  "failed",
+			Code:    fiber.StatusUnauthorized,
+			Errors:  nil,
+		}
+	}
+
+	if err := h.service.Logout(claims, input.RefreshToken); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("logout successfully", 200, nil))
+}
+
+func (h *handler) RevokeUserTokens(c *fiber.Ctx) error {
+
+	idStr := c.Params("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Invalid Convert ID",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  err.Error(),
+		}
+	}
+
+	if err := h.service.RevokeUserTokens(uint(id)); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully revoked user tokens", 200, nil))
+}
+
+func (h *handler) Unlock(c *fiber.Ctx) error {
+
+	idStr := c.Params("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Invalid Convert ID",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  err.Error(),
+		}
+	}
+
+	var input UnlockInput
+
+	if len(c.Body()) != 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return &response.FailedResponseMessage{
+				Message: "Failed to parse request body",
+				Status:  "failed",
+				Code:    fiber.StatusUnprocessableEntity,
+				Errors:  err.Error(),
+			}
+		}
+	}
+
+	validation := response.ValidateBodyRequest(input)
+	if len(validation) != 0 {
+		return &response.FailedResponseMessage{
+			Message: "Failed request body",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  validation,
+		}
+	}
+
+	if err := h.service.Unlock(uint(id), input.IP); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully unlocked user", 200, nil))
+}
+
+// Introspect implements RFC 7662. Its response is not wrapped in the usual
+// envelope because resource servers expect the standard JSON shape. Only
+// callers granted token:introspect, usually resource servers holding a client
+// token with that scope, may use it.
+func (h *handler) Introspect(c *fiber.Ctx) error {
+
+	var input IntrospectInput
+
+	if err := c.BodyParser(&input); err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Failed to parse request body",
+			Status:  "failed",
+			Code:    fiber.StatusUnprocessableEntity,
+			Errors:  err.Error(),
+		}
+	}
+
+	validation := response.ValidateBodyRequest(input)
+	if len(validation) != 0 {
+		return &response.FailedResponseMessage{
+			Message: "Failed request body",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  validation,
+		}
+	}
+
+	result, err := h.service.Introspect(input.Token, input.TokenTypeHint)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// Token is the RFC 6749 token endpoint. Like Introspect it answers in the
+// standard shape rather than the usual envelope.
+func (h *handler) Token(c *fiber.Ctx) error {
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	var input TokenInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(OAuthError{Error: "invalid_request", ErrorDescription: err.Error()})
+	}
+
+	basic := false
+	if clientID, clientSecret, ok := basicAuth(c); ok {
+		input.ClientID, input.ClientSecret, basic = clientID, clientSecret, true
+	}
+
+	switch input.GrantType {
+	case "client_credentials":
+		if input.ClientID == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(OAuthError{Error: "invalid_client", ErrorDescription: "client authentication is required"})
+		}
+		token, err := h.service.ClientCredentials(input.ClientID, input.ClientSecret, input.Scope, input.Audience)
+		if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+			if err.Code == fiber.StatusUnauthorized && basic {
+				c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="token"`)
+			}
+			return oauthError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(token)
+	case "authorization_code":
+		if input.ClientID == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(OAuthError{Error: "invalid_client", ErrorDescription: "client_id is required"})
+		}
+		token, err := h.service.ExchangeAuthorizationCode(input)
+		if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+			if err.Code == fiber.StatusUnauthorized && basic {
+				c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="token"`)
+			}
+			return oauthError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(token)
+	case "refresh_token":
+		token, err := h.service.Refresh(input.RefreshToken, input.ClientID, input.ClientSecret)
+		if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+			switch {
+			case err.Message == "invalid_client":
+				if basic {
+					c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="token"`)
+				}
+			case err.Code == fiber.StatusUnauthorized:
+				err.Code, err.Message = fiber.StatusBadRequest, "invalid_grant"
+			default:
+				err.Message = "server_error"
+			}
+			return oauthError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(token)
+	case "":
+		return c.Status(fiber.StatusBadRequest).JSON(OAuthError{Error: "invalid_request", ErrorDescription: "grant_type is required"})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(OAuthError{Error: "unsupported_grant_type", ErrorDescription: "grant type " + input.GrantType + " is not supported"})
+	}
+}
+
+// UserInfo is the OpenID Connect UserInfo endpoint. Errors are reported in
+// the WWW-Authenticate header as described in RFC 6750.
+func (h *handler) UserInfo(c *fiber.Ctx) error {
+
+	claims, ok := jwt.GetClaims(c)
+	if !ok {
+		return &response.FailedResponseMessage{
+			Message: "Missing token claims",
+			Status:  "failed",
+			Code:    fiber.StatusUnauthorized,
+			Errors:  nil,
+		}
+	}
+
+	info, err := h.service.UserInfo(claims)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		description, _ := err.Errors.(string)
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="`+err.Message+`", error_description="`+description+`"`)
+		return oauthError(c, err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(info)
+}
+
+// basicAuth returns the client credentials from an HTTP Basic Authorization
+// header. RFC 6749 form-encodes both values before they are joined.
+func basicAuth(c *fiber.Ctx) (string, string, bool) {
+
+	header := c.Get(fiber.HeaderAuthorization)
+	if !strings.HasPrefix(header, "Basic ") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+	if err != nil {
+		return "", "", false
+	}
+	clientID, clientSecret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", false
+	}
+	if unescaped, err := url.QueryUnescape(clientID); err == nil {
+		clientID = unescaped
+	}
+	if unescaped, err := url.QueryUnescape(clientSecret); err == nil {
+		clientSecret = unescaped
+	}
+	return clientID, clientSecret, true
+}
+
+func oauthError(c *fiber.Ctx, err response.FailedResponseMessage) error {
+	description, _ := err.Errors.(string)
+	return c.Status(err.Code).JSON(OAuthError{Error: err.Message, ErrorDescription: description})
 }
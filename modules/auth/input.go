@@ -4,5 +4,116 @@ type (
 	LoginInput struct {
 		Username string `json:"username" validate:"required"`
 		Password string `json:"password" validate:"required"`
+		Audience string `json:"audience"`
+	}
+
+	RefreshInput struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+
+	IntrospectInput struct {
+		Token         string `json:"token" form:"token" validate:"required"`
+		TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+	}
+
+	// TokenInput is the RFC 6749 token request. The client may authenticate
+	// with HTTP Basic instead of ClientID and ClientSecret.
+	TokenInput struct {
+		GrantType    string `json:"grant_type" form:"grant_type"`
+		ClientID     string `json:"client_id" form:"client_id"`
+		ClientSecret string `json:"client_secret" form:"client_secret"`
+		Scope        string `json:"scope" form:"scope"`
+		Audience     string `json:"audience" form:"audience"`
+		Code         string `json:"code" form:"code"`
+		RedirectURI  string `json:"redirect_uri" form:"redirect_uri"`
+		CodeVerifier string `json:"code_verifier" form:"code_verifier"`
+		RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	}
+
+	// AuthorizeInput is the RFC 6749 authorization request with the RFC 7636
+	// PKCE parameters. The login page posts it back together with the
+	// credentials and the button the user pressed.
+	AuthorizeInput struct {
+		ResponseType        string `query:"response_type" form:"response_type"`
+		ClientID            string `query:"client_id" form:"client_id"`
+		RedirectURI         string `query:"redirect_uri" form:"redirect_uri"`
+		Scope               string `query:"scope" form:"scope"`
+		State               string `query:"state" form:"state"`
+		CodeChallenge       string `query:"code_challenge" form:"code_challenge"`
+		CodeChallengeMethod string `query:"code_challenge_method" form:"code_challenge_method"`
+		Nonce               string `query:"nonce" form:"nonce"`
+		Username            string `form:"username"`
+		Password            string `form:"password"`
+		OTP                 string `form:"otp"`
+		Action              string `form:"action"`
+		CSRFToken           string `form:"csrf_token"`
+	}
+
+	MFALoginInput struct {
+		MFAToken string `json:"mfa_token" validate:"required"`
+		Code     string `json:"code" validate:"required"`
+	}
+
+	// MFACodeInput carries a TOTP code, or a recovery code where one is accepted.
+	MFACodeInput struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	// UnlockInput optionally names a source IP whose lockout is cleared along
+	// with the user's.
+	UnlockInput struct {
+		IP string `json:"ip" validate:"omitempty,ip"`
+	}
+
+	ChangePasswordInput struct {
+		CurrentPassword string `json:"current_password" validate:"required"`
+		NewPassword     string `json:"new_password" validate:"required"`
+	}
+
+	PasswordResetRequestInput struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	PasswordResetInput struct {
+		Token       string `json:"token" validate:"required"`
+		NewPassword string `json:"new_password" validate:"required"`
+	}
+
+	SignupInput struct {
+		Username string `json:"username" validate:"required"`
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	VerifyEmailInput struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	VerificationRequestInput struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	MagicLinkRequestInput struct {
+		Email    string `json:"email" validate:"required,email"`
+		Audience string `json:"audience"`
+	}
+
+	MagicLinkLoginInput struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	WebAuthnRegistrationInput struct {
+		Name string `json:"name" validate:"max=64"`
+	}
+
+	// WebAuthnLoginInput starts a login with a passkey or security key. The
+	// username is only needed for keys that do not store it themselves.
+	WebAuthnLoginInput struct {
+		Username string `json:"username"`
+		Audience string `json:"audience"`
+	}
+
+	LogoutInput struct {
+		RefreshToken string `json:"refresh_token"`
 	}
 )
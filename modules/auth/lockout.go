@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"errors"
+	"go-jwt/common/response"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockoutPolicy limits password guessing:
+//
+//	LOGIN_MAX_FAILURES       failures per username before it is locked, default 5
+//	LOGIN_IP_MAX_FAILURES    failures per source IP before it is locked, default 20
+//	LOGIN_FAILURE_WINDOW     how long failures are remembered, default 15m
+//	LOGIN_LOCKOUT_DURATION   how long a lock lasts, default 15m
+//	LOGIN_FAILURE_DELAY      delay after the first failure, doubled for each further one, default 250ms
+//	LOGIN_MAX_FAILURE_DELAY  upper bound of the delay, default 5s
+type lockoutPolicy struct {
+	MaxFailures   int
+	IPMaxFailures int
+	Window        time.Duration
+	Lockout       time.Duration
+	Delay         time.Duration
+	MaxDelay      time.Duration
+}
+
+func loadLockoutPolicy() lockoutPolicy {
+	return lockoutPolicy{
+		MaxFailures:   intFromEnv("LOGIN_MAX_FAILURES", 5),
+		IPMaxFailures: intFromEnv("LOGIN_IP_MAX_FAILURES", 20),
+		Window:        durationFromEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		Lockout:       durationFromEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		Delay:         durationFromEnv("LOGIN_FAILURE_DELAY", 250*time.Millisecond),
+		MaxDelay:      durationFromEnv("LOGIN_MAX_FAILURE_DELAY", 5*time.Second),
+	}
+}
+
+// delay is how long to stall the response to the given failed attempt.
+func (p lockoutPolicy) delay(failures int) time.Duration {
+	delay := p.Delay
+	for i := 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+func userLockoutKey(username string) string {
+	return "user:" + username
+}
+
+func ipLockoutKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkLockout rejects the attempt while the username or the source IP is
+// locked. The username is locked the same way whether or not it exists.
+func checkLockout(authRepo Repository, username, ip string) response.FailedResponseMessage {
+
+	failures, err := authRepo.FindLoginFailures(userLockoutKey(username), ipLockoutKey(ip))
+	if err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to check login attempts",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	for _, failure := range failures {
+		if failure.LockedUntil != nil && time.Now().Before(*failure.LockedUntil) {
+			return lockedResponse(failure)
+		}
+	}
+	return response.FailedResponseMessage{}
+}
+
+// loginFailed counts a failed attempt against the username and the source IP,
+// stalls the response progressively and reports a lock as soon as it starts.
+func loginFailed(authRepo Repository, username, ip string) response.FailedResponseMessage {
+
+	policy := loadLockoutPolicy()
+
+	failed := response.FailedResponseMessage{
+		Message: "Invalid username or password",
+		Status:  "failed",
+		Code:    http.StatusUnauthorized,
+		Errors:  "Invalid username or password",
+	}
+
+	limits := []struct {
+		key         string
+		maxFailures int
+	}{
+		{ipLockoutKey(ip), policy.IPMaxFailures},
+		{userLockoutKey(username), policy.MaxFailures},
+	}
+
+	attempts := 0
+	for _, limit := range limits {
+		failure, err := authRepo.RecordLoginFailure(limit.key, limit.maxFailures, policy.Window, policy.Lockout)
+		if err != nil {
+			return response.FailedResponseMessage{
+				Message: "Failed to record login attempt",
+				Status:  "failed",
+				Code:    http.StatusInternalServerError,
+				Errors:  err.Error(),
+			}
+		}
+		if failure.LockedUntil != nil && time.Now().Before(*failure.LockedUntil) {
+			failed = lockedResponse(failure)
+		}
+		if failure.Failures > attempts {
+			attempts = failure.Failures
+		}
+	}
+
+	time.Sleep(policy.delay(attempts))
+	return failed
+}
+
+// Unlock clears the failed logins of the user and, when given, of a source IP.
+func (s *service) Unlock(userID uint, ip string) response.FailedResponseMessage {
+
+	user, err := s.userRepo.FindOneUserByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.FailedResponseMessage{
+				Message: "User not found",
+				Status:  "failed",
+				Code:    http.StatusNotFound,
+				Errors:  err.Error(),
+			}
+		}
+		return response.FailedResponseMessage{
+			Message: "Failed to find user",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	keys := []string{userLockoutKey(user.Username)}
+	if ip != "" {
+		keys = append(keys, ipLockoutKey(ip))
+	}
+
+	if err := s.authRepo.DeleteLoginFailures(keys...); err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to unlock user",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	return response.FailedResponseMessage{}
+}
+
+func lockedResponse(failure LoginFailure) response.FailedResponseMessage {
+
+	retryIn := time.Until(*failure.LockedUntil).Round(time.Second)
+
+	if strings.HasPrefix(failure.Key, userLockoutKey("")) {
+		return response.FailedResponseMessage{
+			Message: "Account temporarily locked",
+			Status:  "failed",
+			Code:    http.StatusTooManyRequests,
+			Errors:  "too many failed login attempts, try again in " + retryIn.String(),
+		}
+	}
+	return response.FailedResponseMessage{
+		Message: "Too many failed login attempts",
+		Status:  "failed",
+		Code:    http.StatusTooManyRequests,
+		Errors:  "too many failed login attempts from this address, try again in " + retryIn.String(),
+	}
+}
+
+func intFromEnv(name string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDelay(t *testing.T) {
+
+	policy := lockoutPolicy{Delay: 250 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 250 * time.Millisecond},
+		{1, 250 * time.Millisecond},
+		{2, 500 * time.Millisecond},
+		{3, time.Second},
+		{5, 4 * time.Second},
+		{6, 5 * time.Second},
+		{1000, 5 * time.Second},
+	}
+	for _, test := range tests {
+		if got := policy.delay(test.failures); got != test.want {
+			t.Errorf("delay(%d) = %s, want %s", test.failures, got, test.want)
+		}
+	}
+
+	capped := lockoutPolicy{Delay: time.Minute, MaxDelay: time.Second}
+	if got := capped.delay(1); got != time.Second {
+		t.Errorf("delay above the maximum = %s, want %s", got, time.Second)
+	}
+}
+
+func TestLoadLockoutPolicy(t *testing.T) {
+
+	defaults := lockoutPolicy{
+		MaxFailures:   5,
+		IPMaxFailures: 20,
+		Window:        15 * time.Minute,
+		Lockout:       15 * time.Minute,
+		Delay:         250 * time.Millisecond,
+		MaxDelay:      5 * time.Second,
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want lockoutPolicy
+	}{
+		{"defaults", nil, defaults},
+		{
+			"overrides",
+			map[string]string{
+				"LOGIN_MAX_FAILURES":      "3",
+				"LOGIN_IP_MAX_FAILURES":   "50",
+				"LOGIN_FAILURE_WINDOW":    "1h",
+				"LOGIN_LOCKOUT_DURATION":  "30m",
+				"LOGIN_FAILURE_DELAY":     "1s",
+				"LOGIN_MAX_FAILURE_DELAY": "10s",
+			},
+			lockoutPolicy{
+				MaxFailures:   3,
+				IPMaxFailures: 50,
+				Window:        time.Hour,
+				Lockout:       30 * time.Minute,
+				Delay:         time.Second,
+				MaxDelay:      10 * time.Second,
+			},
+		},
+		{
+			"invalid values are ignored",
+			map[string]string{
+				"LOGIN_MAX_FAILURES":      "five",
+				"LOGIN_IP_MAX_FAILURES":   "0",
+				"LOGIN_FAILURE_WINDOW":    "15",
+				"LOGIN_LOCKOUT_DURATION":  "-1m",
+				"LOGIN_FAILURE_DELAY":     "0s",
+				"LOGIN_MAX_FAILURE_DELAY": "",
+			},
+			defaults,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{
+				"LOGIN_MAX_FAILURES", "LOGIN_IP_MAX_FAILURES", "LOGIN_FAILURE_WINDOW",
+				"LOGIN_LOCKOUT_DURATION", "LOGIN_FAILURE_DELAY", "LOGIN_MAX_FAILURE_DELAY",
+			} {
+				t.Setenv(name, test.env[name])
+			}
+			if got := loadLockoutPolicy(); got != test.want {
+				t.Errorf("loadLockoutPolicy = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
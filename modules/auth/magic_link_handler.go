@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"go-jwt/common/response"
+	"net/http"
+	"reflect"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *handler) RequestMagicLink(c *fiber.Ctx) error {
+
+	var input MagicLinkRequestInput
+	if errParse := parseAndValidate(c, &input); errParse != nil {
+		return errParse
+	}
+
+	if err := h.service.RequestMagicLink(input.Email, input.Audience); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(response.BuildSuccessResponseMessage("if an account uses this email address, a login link has been sent to it", http.StatusAccepted, nil))
+}
+
+func (h *handler) LoginMagicLink(c *fiber.Ctx) error {
+
+	var input MagicLinkLoginInput
+	if errParse := parseAndValidate(c, &input); errParse != nil {
+		return errParse
+	}
+
+	token, challenge, err := h.service.LoginWithMagicLink(input.Token, c.IP(), c.Get(fiber.HeaderUserAgent))
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	if challenge != nil {
+		return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("mfa required", 200, challenge))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("login successfully", 200, token))
+}
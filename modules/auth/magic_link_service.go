@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"errors"
+	"go-jwt/common/jwt"
+	"go-jwt/common/notify"
+	"go-jwt/common/response"
+	"go-jwt/modules/user"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultMagicLinkTTL = 10 * time.Minute
+
+// RequestMagicLink mails a one-time login link to every user with the given
+// email address, when MAGIC_LINK_ENABLED is set. Like the password reset
+// request, it succeeds whether or not there are any.
+func (s *service) RequestMagicLink(email, audience string) response.FailedResponseMessage {
+
+	if !boolFromEnv("MAGIC_LINK_ENABLED", false) {
+		return response.FailedResponseMessage{
+			Message: "Magic link login is disabled",
+			Status:  "failed",
+			Code:    http.StatusForbidden,
+			Errors:  "log in with a username and password instead",
+		}
+	}
+
+	if audience != "" && !jwt.GetPolicy().Accepts(audience) {
+		return response.FailedResponseMessage{
+			Message: "Invalid audience",
+			Status:  "failed",
+			Code:    http.StatusBadRequest,
+			Errors:  "audience " + audience + " is not allowed",
+		}
+	}
+
+	users, err := s.userRepo.FindUsersByCriteria(user.User{Email: email})
+	if err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to find user",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	ttl := durationFromEnv("MAGIC_LINK_TTL", defaultMagicLinkTTL)
+
+	for _, found := range users {
+
+		token, claims, err := jwt.GenerateMagicLinkToken(strconv.FormatUint(uint64(found.ID), 10), ttl)
+		if err != nil {
+			return response.FailedResponseMessage{
+				Message: "Failed to generate magic link",
+				Status:  "failed",
+				Code:    http.StatusInternalServerError,
+				Errors:  err.Error(),
+			}
+		}
+
+		if _, err := s.authRepo.SaveMagicLink(MagicLink{
+			JTI:       claims.ID,
+			UserID:    found.ID,
+			Email:     found.Email,
+			Audience:  audience,
+			ExpiresAt: claims.ExpiresAt.Time,
+		}); err != nil {
+			return response.FailedResponseMessage{
+				Message: "Failed to save magic link",
+				Status:  "failed",
+				Code:    http.StatusInternalServerError,
+				Errors:  err.Error(),
+			}
+		}
+
+		message := magicLinkMessage(found, token, ttl)
+		go func(userID uint) {
+			if err := notify.Send(message); err != nil {
+				log.Printf("failed to send magic link to user %d: %v", userID, err)
+			}
+		}(found.ID)
+	}
+
+	return response.FailedResponseMessage{}
+}
+
+// LoginWithMagicLink uses up a magic link and answers like Login: with tokens,
+// or with an MFA challenge for users who have a second factor, since the link
+// only replaces the password.
+func (s *service) LoginWithMagicLink(token, ip, userAgent string) (TokenResponse, *MFAChallengeResponse, response.FailedResponseMessage) {
+
+	invalid := response.FailedResponseMessage{
+		Message: "Invalid magic link",
+		Status:  "failed",
+		Code:    http.StatusUnauthorized,
+		Errors:  "the link is invalid, expired or already used, please request a new one",
+	}
+
+	claims, err := jwt.VerifyMagicLinkToken(token)
+	if err != nil {
+		return TokenResponse{}, nil, invalid
+	}
+
+	link, err := s.authRepo.FindMagicLinkByJTI(claims.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return TokenResponse{}, nil, invalid
+		}
+		return TokenResponse{}, nil, response.FailedResponseMessage{
+			Message: "Failed to find magic link",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	if link.UsedAt != nil || strconv.FormatUint(uint64(link.UserID), 10) != claims.Subject {
+		return TokenResponse{}, nil, invalid
+	}
+
+	used, err := s.authRepo.UseMagicLink(link.ID)
+	if err != nil {
+		return TokenResponse{}, nil, response.FailedResponseMessage{
+			Message: "Failed to use magic link",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	if !used {
+		return TokenResponse{}, nil, invalid
+	}
+
+	found, err := s.userRepo.FindOneUserByID(link.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return TokenResponse{}, nil, invalid
+		}
+		return TokenResponse{}, nil, mfaFailedResponse(err, "Failed to find user")
+	}
+	if found.Email != link.Email {
+		return TokenResponse{}, nil, invalid
+	}
+
+	if errVerified := checkEmailVerified(found); !reflect.DeepEqual(errVerified, response.FailedResponseMessage{}) {
+		return TokenResponse{}, nil, errVerified
+	}
+
+	challenge, errMFA := s.startMFAChallenge(found, link.Audience)
+	if !reflect.DeepEqual(errMFA, response.FailedResponseMessage{}) {
+		return TokenResponse{}, nil, errMFA
+	}
+	if challenge != nil {
+		return TokenResponse{}, challenge, response.FailedResponseMessage{}
+	}
+
+	tokens, errIssue := s.issueTokens(found, link.Audience, ip, userAgent)
+	return tokens, nil, errIssue
+}
+
+// magicLinkMessage links to MAGIC_LINK_URL with the token when it is set, and
+// otherwise contains the bare token. The link should lead to a page that
+// posts the token, as mail scanners follow links and would use it up.
+func magicLinkMessage(found user.User, token string, ttl time.Duration) notify.Message {
+
+	instructions := "Use this token to log in:\n\n" + token
+	if loginURL, err := url.Parse(os.Getenv("MAGIC_LINK_URL")); err == nil && loginURL.String() != "" {
+		query := loginURL.Query()
+		query.Set("token", token)
+		loginURL.RawQuery = query.Encode()
+		instructions = "Open this link to log in:\n\n" + loginURL.String()
+	}
+
+	return notify.Message{
+		To:      found.Email,
+		Subject: "Your login link",
+		Body: "Hello " + found.Username + ",\n\n" +
+			"Someone asked to log in to your account without a password. " + instructions + "\n\n" +
+			"It expires in " + ttl.String() + " and can only be used once. " +
+			"If you did not ask for this, you can ignore this message.",
+	}
+}
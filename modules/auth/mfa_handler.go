@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"go-jwt/common/jwt"
+	"go-jwt/common/response"
+	"reflect"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *handler) EnrollTOTP(c *fiber.Ctx) error {
+
+	userID, errUser := currentUserID(c)
+	if errUser != nil {
+		return errUser
+	}
+
+	enrolment, err := h.mfaService.EnrollTOTP(userID)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully started TOTP enrolment", 200, enrolment))
+}
+
+func (h *handler) TOTPQRCode(c *fiber.Ctx) error {
+
+	userID, errUser := currentUserID(c)
+	if errUser != nil {
+		return errUser
+	}
+
+	png, err := h.mfaService.TOTPQRCode(userID)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderContentType, "image/png")
+	return c.Status(fiber.StatusOK).Send(png)
+}
+
+func (h *handler) ConfirmTOTP(c *fiber.Ctx) error {
+
+	userID, errUser := currentUserID(c)
+	if errUser != nil {
+		return errUser
+	}
+
+	input, errInput := parseCodeInput(c)
+	if errInput != nil {
+		return errInput
+	}
+
+	codes, err := h.mfaService.ConfirmTOTP(userID, input.Code, c.IP())
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully enabled TOTP", 200, codes))
+}
+
+func (h *handler) DisableTOTP(c *fiber.Ctx) error {
+
+	userID, errUser := currentUserID(c)
+	if errUser != nil {
+		return errUser
+	}
+
+	input, errInput := parseCodeInput(c)
+	if errInput != nil {
+		return errInput
+	}
+
+	if err := h.mfaService.DisableTOTP(userID, input.Code, c.IP()); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully disabled TOTP", 200, nil))
+}
+
+func (h *handler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+
+	userID, errUser := currentUserID(c)
+	if errUser != nil {
+		return errUser
+	}
+
+	input, errInput := parseCodeInput(c)
+	if errInput != nil {
+		return errInput
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(userID, input.Code, c.IP())
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully regenerated recovery codes", 200, codes))
+}
+
+// currentUserID returns the ID of the user the access token was issued to.
+// Client tokens have no user and are refused.
+func currentUserID(c *fiber.Ctx) (uint, error) {
+
+	claims, ok := jwt.GetClaims(c)
+	if !ok {
+		return 0, &response.FailedResponseMessage{
+			Message: "Missing token claims",
+			Status:  "failed",
+			Code:    fiber.StatusUnauthorized,
+			Errors:  nil,
+		}
+	}
+
+	id, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if claims.IsClientToken() || claims.IsDelegated() || err != nil {
+		return 0, &response.FailedResponseMessage{
+			Message: "Forbidden",
+			Status:  "failed",
+			Code:    fiber.StatusForbidden,
+			Errors:  "this endpoint requires a user token",
+		}
+	}
+	return uint(id), nil
+}
+
+func parseCodeInput(c *fiber.Ctx) (MFACodeInput, error) {
+
+	var input MFACodeInput
+
+	if err := c.BodyParser(&input); err != nil {
+		return input, &response.FailedResponseMessage{
+			Message: "Failed to parse request body",
+			Status:  "failed",
+			Code:    fiber.StatusUnprocessableEntity,
+			Errors:  err.Error(),
+		}
+	}
+
+	validation := response.ValidateBodyRequest(input)
+	if len(validation) != 0 {
+		return input, &response.FailedResponseMessage{
+			Message: "Failed request body",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  validation,
+		}
+	}
+	return input, nil
+}
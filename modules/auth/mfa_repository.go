@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MFARepository interface {
+	SaveTOTPCredential(credential TOTPCredential) (TOTPCredential, error)
+	FindTOTPCredentialByUserID(userID uint) (TOTPCredential, error)
+	ConfirmTOTPCredential(userID uint, step int64, codeHashes []string) error
+	UseTOTPStep(userID uint, step int64) (bool, error)
+	DeleteTOTPCredential(userID uint) error
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)
+	SaveMFAChallenge(challenge MFAChallenge) (MFAChallenge, error)
+	FindMFAChallengeByHash(tokenHash string) (MFAChallenge, error)
+	ConsumeMFAChallenge(id uint) (bool, error)
+}
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+// SaveTOTPCredential replaces the enrolment of the user, confirmed or not.
+func (r *mfaRepository) SaveTOTPCredential(credential TOTPCredential) (TOTPCredential, error) {
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&TOTPCredential{UserID: credential.UserID}).Delete(&TOTPCredential{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&credential).Error
+	})
+	if err != nil {
+		return TOTPCredential{}, err
+	}
+	return credential, nil
+}
+
+// FindTOTPCredentialByUserID is checked on every login. It uses Find rather
+// than First because most users have no credential and the miss must not be
+// logged as an error.
+func (r *mfaRepository) FindTOTPCredentialByUserID(userID uint) (TOTPCredential, error) {
+	var credentials []TOTPCredential
+	if err := r.db.Where(&TOTPCredential{UserID: userID}).Limit(1).Find(&credentials).Error; err != nil {
+		return TOTPCredential{}, err
+	}
+	if len(credentials) == 0 {
+		return TOTPCredential{}, gorm.ErrRecordNotFound
+	}
+	return credentials[0], nil
+}
+
+// ConfirmTOTPCredential enables the enrolment with the step of the code that
+// confirmed it and stores the first set of recovery codes.
+func (r *mfaRepository) ConfirmTOTPCredential(userID uint, step int64, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&TOTPCredential{}).Where(&TOTPCredential{UserID: userID}).Updates(map[string]interface{}{
+			"confirmed_at":   time.Now(),
+			"last_used_step": step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// UseTOTPStep records step as used and reports false when it, or a later
+// step, has been used before.
+func (r *mfaRepository) UseTOTPStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&TOTPCredential{}).Where("user_id = ? AND last_used_step < ?", userID, step).Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *mfaRepository) DeleteTOTPCredential(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(&TOTPCredential{UserID: userID}).Delete(&TOTPCredential{}).Error; err != nil {
+			return err
+		}
+		return tx.Where(&RecoveryCode{UserID: userID}).Delete(&RecoveryCode{}).Error
+	})
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codeHashes []string) error {
+
+	if err := tx.Where(&RecoveryCode{UserID: userID}).Delete(&RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]RecoveryCode, 0, len(codeHashes))
+	for _, codeHash := range codeHashes {
+		codes = append(codes, RecoveryCode{UserID: userID, CodeHash: codeHash})
+	}
+	return tx.Create(&codes).Error
+}
+
+// UseRecoveryCode marks the unused recovery code with the given hash as used.
+func (r *mfaRepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&RecoveryCode{}).Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *mfaRepository) SaveMFAChallenge(challenge MFAChallenge) (MFAChallenge, error) {
+	if err := r.db.Create(&challenge).Error; err != nil {
+		return MFAChallenge{}, err
+	}
+	return challenge, nil
+}
+
+func (r *mfaRepository) FindMFAChallengeByHash(tokenHash string) (MFAChallenge, error) {
+	var challenge MFAChallenge
+	if err := r.db.Where(&MFAChallenge{TokenHash: tokenHash}).First(&challenge).Error; err != nil {
+		return MFAChallenge{}, err
+	}
+	return challenge, nil
+}
+
+// ConsumeMFAChallenge marks the challenge as used and reports false when it
+// already was, so concurrent requests cannot both exchange it.
+func (r *mfaRepository) ConsumeMFAChallenge(id uint) (bool, error) {
+
+	consumed := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		var challenge MFAChallenge
+		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&challenge, id).Error; err != nil {
+			return err
+		}
+		if challenge.UsedAt != nil {
+			return nil
+		}
+
+		consumed = true
+		return tx.Model(&challenge).Update("used_at", time.Now()).Error
+	})
+
+	return consumed, err
+}
@@ -0,0 +1,427 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"go-jwt/common/jwt"
+	"go-jwt/common/response"
+	"go-jwt/common/totp"
+	"go-jwt/modules/user"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMFAChallengeTTL = 5 * time.Minute
+	recoveryCodeCount      = 10
+)
+
+type MFAService interface {
+	EnrollTOTP(userID uint) (TOTPEnrolment, response.FailedResponseMessage)
+	TOTPQRCode(userID uint) ([]byte, response.FailedResponseMessage)
+	ConfirmTOTP(userID uint, code, ip string) (RecoveryCodesResponse, response.FailedResponseMessage)
+	DisableTOTP(userID uint, code, ip string) response.FailedResponseMessage
+	RegenerateRecoveryCodes(userID uint, code, ip string) (RecoveryCodesResponse, response.FailedResponseMessage)
+}
+
+type mfaService struct {
+	repo     MFARepository
+	userRepo user.Repository
+	authRepo Repository
+}
+
+func NewMFAService(repo MFARepository, userRepo user.Repository, authRepo Repository) MFAService {
+	return &mfaService{repo: repo, userRepo: userRepo, authRepo: authRepo}
+}
+
+// EnrollTOTP starts a new enrolment. It replaces an unconfirmed one, but an
+// enabled authenticator must be disabled first.
+func (s *mfaService) EnrollTOTP(userID uint) (TOTPEnrolment, response.FailedResponseMessage) {
+
+	found, err := s.userRepo.FindOneUserByID(userID)
+	if err != nil {
+		return TOTPEnrolment{}, mfaFailedResponse(err, "Failed to find user")
+	}
+
+	if _, errEnabled := s.pendingCredential(userID); errEnabled.Code == http.StatusConflict {
+		return TOTPEnrolment{}, errEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return TOTPEnrolment{}, mfaFailedResponse(err, "Failed to generate TOTP secret")
+	}
+
+	if _, err := s.repo.SaveTOTPCredential(TOTPCredential{UserID: userID, Secret: secret}); err != nil {
+		return TOTPEnrolment{}, mfaFailedResponse(err, "Failed to save TOTP enrolment")
+	}
+
+	return TOTPEnrolment{
+		Secret: secret,
+		URI:    totp.URI(jwt.GetPolicy().Issuer, found.Username, secret),
+	}, response.FailedResponseMessage{}
+}
+
+// TOTPQRCode renders the otpauth URI of an unconfirmed enrolment as a PNG.
+func (s *mfaService) TOTPQRCode(userID uint) ([]byte, response.FailedResponseMessage) {
+
+	credential, errPending := s.pendingCredential(userID)
+	if !reflect.DeepEqual(errPending, response.FailedResponseMessage{}) {
+		return nil, errPending
+	}
+
+	found, err := s.userRepo.FindOneUserByID(userID)
+	if err != nil {
+		return nil, mfaFailedResponse(err, "Failed to find user")
+	}
+
+	png, err := qrcode.Encode(totp.URI(jwt.GetPolicy().Issuer, found.Username, credential.Secret), qrcode.Medium, 256)
+	if err != nil {
+		return nil, mfaFailedResponse(err, "Failed to render QR code")
+	}
+	return png, response.FailedResponseMessage{}
+}
+
+// ConfirmTOTP enables the enrolment once the user proves their app produces
+// valid codes, and returns the recovery codes. They are only shown once.
+// Wrong codes count towards the login lockout like in DisableTOTP.
+func (s *mfaService) ConfirmTOTP(userID uint, code, ip string) (RecoveryCodesResponse, response.FailedResponseMessage) {
+
+	found, err := s.userRepo.FindOneUserByID(userID)
+	if err != nil {
+		return RecoveryCodesResponse{}, mfaFailedResponse(err, "Failed to find user")
+	}
+
+	credential, errPending := s.pendingCredential(userID)
+	if !reflect.DeepEqual(errPending, response.FailedResponseMessage{}) {
+		return RecoveryCodesResponse{}, errPending
+	}
+
+	if errLocked := checkLockout(s.authRepo, found.Username, ip); !reflect.DeepEqual(errLocked, response.FailedResponseMessage{}) {
+		return RecoveryCodesResponse{}, errLocked
+	}
+
+	step, ok := totp.Validate(credential.Secret, code, time.Now())
+	if !ok {
+		return RecoveryCodesResponse{}, s.codeFailed(found.Username, ip)
+	}
+
+	codes, codeHashes, err := newRecoveryCodes()
+	if err != nil {
+		return RecoveryCodesResponse{}, mfaFailedResponse(err, "Failed to generate recovery codes")
+	}
+
+	if err := s.repo.ConfirmTOTPCredential(userID, step, codeHashes); err != nil {
+		return RecoveryCodesResponse{}, mfaFailedResponse(err, "Failed to confirm TOTP enrolment")
+	}
+
+	return RecoveryCodesResponse{RecoveryCodes: codes}, response.FailedResponseMessage{}
+}
+
+// DisableTOTP removes the authenticator and the recovery codes. It takes a
+// TOTP or a recovery code so a stolen access token alone cannot turn MFA off.
+// Wrong codes count towards the login lockout of the user, as in VerifyMFA.
+func (s *mfaService) DisableTOTP(userID uint, code, ip string) response.FailedResponseMessage {
+
+	found, err := s.userRepo.FindOneUserByID(userID)
+	if err != nil {
+		return mfaFailedResponse(err, "Failed to find user")
+	}
+
+	if errLocked := checkLockout(s.authRepo, found.Username, ip); !reflect.DeepEqual(errLocked, response.FailedResponseMessage{}) {
+		return errLocked
+	}
+
+	valid, err := verifySecondFactor(s.repo, userID, code)
+	if err != nil {
+		return mfaFailedResponse(err, "Failed to verify code")
+	}
+	if !valid {
+		return s.codeFailed(found.Username, ip)
+	}
+
+	if err := s.repo.DeleteTOTPCredential(userID); err != nil {
+		return mfaFailedResponse(err, "Failed to disable TOTP")
+	}
+	return response.FailedResponseMessage{}
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user. Wrong
+// codes count towards the login lockout like in DisableTOTP.
+func (s *mfaService) RegenerateRecoveryCodes(userID uint, code, ip string) (RecoveryCodesResponse, response.FailedResponseMessage) {
+
+	found, err := s.userRepo.FindOneUserByID(userID)
+	if err != nil {
+		return RecoveryCodesResponse{}, mfaFailedResponse(err, "Failed to find user")
+	}
+
+	credential, err := s.repo.FindTOTPCredentialByUserID(userID)
+	if err != nil || credential.ConfirmedAt == nil {
+		return RecoveryCodesResponse{}, response.FailedResponseMessage{
+			Message: "TOTP is not enabled",
+			Status:  "failed",
+			Code:    http.StatusNotFound,
+			Errors:  "enable TOTP before generating recovery codes",
+		}
+	}
+
+	if errLocked := checkLockout(s.authRepo, found.Username, ip); !reflect.DeepEqual(errLocked, response.FailedResponseMessage{}) {
+		return RecoveryCodesResponse{}, errLocked
+	}
+
+	step, ok := totp.Validate(credential.Secret, code, time.Now())
+	if !ok {
+		return RecoveryCodesResponse{}, s.codeFailed(found.Username, ip)
+	}
+	if unused, err := s.repo.UseTOTPStep(userID, step); err != nil || !unused {
+		return RecoveryCodesResponse{}, s.codeFailed(found.Username, ip)
+	}
+
+	codes, codeHashes, err := newRecoveryCodes()
+	if err != nil {
+		return RecoveryCodesResponse{}, mfaFailedResponse(err, "Failed to generate recovery codes")
+	}
+	if err := s.repo.ReplaceRecoveryCodes(userID, codeHashes); err != nil {
+		return RecoveryCodesResponse{}, mfaFailedResponse(err, "Failed to save recovery codes")
+	}
+
+	return RecoveryCodesResponse{RecoveryCodes: codes}, response.FailedResponseMessage{}
+}
+
+// codeFailed counts a wrong code towards the login lockout of the user.
+func (s *mfaService) codeFailed(username, ip string) response.FailedResponseMessage {
+	if errFailed := loginFailed(s.authRepo, username, ip); errFailed.Code != http.StatusUnauthorized {
+		return errFailed
+	}
+	return invalidCodeResponse()
+}
+
+// pendingCredential returns the unconfirmed enrolment of the user.
+func (s *mfaService) pendingCredential(userID uint) (TOTPCredential, response.FailedResponseMessage) {
+
+	credential, err := s.repo.FindTOTPCredentialByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return TOTPCredential{}, response.FailedResponseMessage{
+				Message: "TOTP enrolment not found",
+				Status:  "failed",
+				Code:    http.StatusNotFound,
+				Errors:  "start an enrolment first",
+			}
+		}
+		return TOTPCredential{}, mfaFailedResponse(err, "Failed to find TOTP enrolment")
+	}
+
+	if credential.ConfirmedAt != nil {
+		return TOTPCredential{}, response.FailedResponseMessage{
+			Message: "TOTP is already enabled",
+			Status:  "failed",
+			Code:    http.StatusConflict,
+			Errors:  "disable TOTP before enrolling again",
+		}
+	}
+	return credential, response.FailedResponseMessage{}
+}
+
+// startMFAChallenge returns a challenge when the user has enabled MFA, and
+// nil when the password alone is enough.
+func (s *service) startMFAChallenge(found user.User, audience string) (*MFAChallengeResponse, response.FailedResponseMessage) {
+
+	enabled, err := mfaEnabled(s.mfaRepo, found.ID)
+	if err != nil {
+		return nil, mfaFailedResponse(err, "Failed to check MFA")
+	}
+	if !enabled {
+		return nil, response.FailedResponseMessage{}
+	}
+
+	token, tokenHash, err := newRefreshToken()
+	if err != nil {
+		return nil, mfaFailedResponse(err, "Failed to generate MFA token")
+	}
+
+	ttl := durationFromEnv("MFA_CHALLENGE_TTL", defaultMFAChallengeTTL)
+	if _, err := s.mfaRepo.SaveMFAChallenge(MFAChallenge{
+		TokenHash: tokenHash,
+		UserID:    found.ID,
+		Audience:  audience,
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return nil, mfaFailedResponse(err, "Failed to save MFA challenge")
+	}
+
+	return &MFAChallengeResponse{
+		MFAToken:  token,
+		ExpiresIn: int64(ttl.Seconds()),
+		Methods:   []string{"totp", "recovery_code"},
+	}, response.FailedResponseMessage{}
+}
+
+// VerifyMFA exchanges a challenge and a TOTP or recovery code for tokens.
+// Wrong codes count towards the login lockout of the user.
+func (s *service) VerifyMFA(mfaToken, code, ip, userAgent string) (TokenResponse, response.FailedResponseMessage) {
+
+	challenge, err := s.mfaRepo.FindMFAChallengeByHash(hashToken(mfaToken))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return TokenResponse{}, mfaFailedResponse(err, "Failed to find MFA challenge")
+	}
+	if err != nil || challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Invalid MFA token",
+			Status:  "failed",
+			Code:    http.StatusUnauthorized,
+			Errors:  "the MFA token is invalid or expired, please log in again",
+		}
+	}
+
+	found, err := s.userRepo.FindOneUserByID(challenge.UserID)
+	if err != nil {
+		return TokenResponse{}, mfaFailedResponse(err, "Failed to find user")
+	}
+
+	if errSecond := s.checkSecondFactor(found, code, ip); !reflect.DeepEqual(errSecond, response.FailedResponseMessage{}) {
+		return TokenResponse{}, errSecond
+	}
+
+	consumed, err := s.mfaRepo.ConsumeMFAChallenge(challenge.ID)
+	if err != nil {
+		return TokenResponse{}, mfaFailedResponse(err, "Failed to consume MFA challenge")
+	}
+	if !consumed {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Invalid MFA token",
+			Status:  "failed",
+			Code:    http.StatusUnauthorized,
+			Errors:  "the MFA token has already been used",
+		}
+	}
+
+	return s.issueTokens(found, challenge.Audience, ip, userAgent)
+}
+
+// checkSecondFactor verifies code for a user with MFA enabled. Users without
+// MFA pass without a code.
+func (s *service) checkSecondFactor(found user.User, code, ip string) response.FailedResponseMessage {
+
+	enabled, err := mfaEnabled(s.mfaRepo, found.ID)
+	if err != nil {
+		return mfaFailedResponse(err, "Failed to check MFA")
+	}
+	if !enabled {
+		return response.FailedResponseMessage{}
+	}
+
+	if errLocked := checkLockout(s.authRepo, found.Username, ip); !reflect.DeepEqual(errLocked, response.FailedResponseMessage{}) {
+		return errLocked
+	}
+
+	if code == "" {
+		return response.FailedResponseMessage{
+			Message: "MFA code required",
+			Status:  "failed",
+			Code:    http.StatusUnauthorized,
+			Errors:  "enter the code from your authenticator app or a recovery code",
+		}
+	}
+
+	valid, err := verifySecondFactor(s.mfaRepo, found.ID, code)
+	if err != nil {
+		return mfaFailedResponse(err, "Failed to verify code")
+	}
+	if !valid {
+		if errFailed := loginFailed(s.authRepo, found.Username, ip); errFailed.Code != http.StatusUnauthorized {
+			return errFailed
+		}
+		errInvalid := invalidCodeResponse()
+		errInvalid.Code = http.StatusUnauthorized
+		return errInvalid
+	}
+	return response.FailedResponseMessage{}
+}
+
+func mfaEnabled(repo MFARepository, userID uint) (bool, error) {
+	credential, err := repo.FindTOTPCredentialByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return credential.ConfirmedAt != nil, nil
+}
+
+// verifySecondFactor accepts a TOTP code that was not used before, or an
+// unused recovery code.
+func verifySecondFactor(repo MFARepository, userID uint, code string) (bool, error) {
+
+	credential, err := repo.FindTOTPCredentialByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if credential.ConfirmedAt == nil {
+		return false, nil
+	}
+
+	if step, ok := totp.Validate(credential.Secret, code, time.Now()); ok {
+		return repo.UseTOTPStep(userID, step)
+	}
+	return repo.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(code)))
+}
+
+// newRecoveryCodes returns codes like "k3f9a-2mx7q" and the hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+
+	codes := make([]string, 0, recoveryCodeCount)
+	codeHashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		encoded := strings.ToLower(base32.StdEncoding.EncodeToString(buf))[:10]
+		codes = append(codes, encoded[:5]+"-"+encoded[5:])
+		codeHashes = append(codeHashes, hashToken(encoded))
+	}
+	return codes, codeHashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func invalidCodeResponse() response.FailedResponseMessage {
+	return response.FailedResponseMessage{
+		Message: "Invalid code",
+		Status:  "failed",
+		Code:    http.StatusBadRequest,
+		Errors:  "the code is invalid or has already been used",
+	}
+}
+
+func mfaFailedResponse(err error, message string) response.FailedResponseMessage {
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.FailedResponseMessage{
+			Message: "User not found",
+			Status:  "failed",
+			Code:    http.StatusNotFound,
+			Errors:  err.Error(),
+		}
+	}
+	return response.FailedResponseMessage{
+		Message: message,
+		Status:  "failed",
+		Code:    http.StatusInternalServerError,
+		Errors:  err.Error(),
+	}
+}
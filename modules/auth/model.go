@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"time"
+)
+
+type (
+	// RefreshToken is an opaque, single-use refresh token. Only the SHA-256 hash
+	// of the token is stored. Every token issued by rotating another one shares
+	// its FamilyID so the whole chain can be revoked at once.
+	RefreshToken struct {
+		ID        uint       `gorm:"primarykey" json:"id"`
+		CreatedAt time.Time  `json:"created_at"`
+		UpdatedAt time.Time  `json:"updated_at"`
+		FamilyID  string     `gorm:"not null;index" json:"family_id"`
+		UserID    uint       `gorm:"not null;index" json:"user_id"`
+		Audience  string     `json:"audience"`
+		ClientID  string     `json:"client_id"`
+		Scope     string     `json:"scope"`
+		AuthTime  time.Time  `json:"auth_time"`
+		TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+		ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+		UsedAt    *time.Time `json:"used_at"`
+		RevokedAt *time.Time `json:"revoked_at"`
+	}
+
+	// AuthorizationCode is a single-use code issued by the authorization
+	// endpoint. Only the SHA-256 hash of the code is stored. The refresh tokens
+	// issued in exchange for it share FamilyID, so replaying the code revokes them.
+	// The exchange starts the session FamilyID, signed in from IP with UserAgent.
+	AuthorizationCode struct {
+		ID            uint       `gorm:"primarykey" json:"id"`
+		CreatedAt     time.Time  `json:"created_at"`
+		CodeHash      string     `gorm:"not null;uniqueIndex" json:"-"`
+		ClientID      string     `gorm:"not null;index" json:"client_id"`
+		UserID        uint       `gorm:"not null" json:"user_id"`
+		RedirectURI   string     `gorm:"not null" json:"redirect_uri"`
+		Scope         string     `json:"scope"`
+		Audience      string     `json:"audience"`
+		CodeChallenge string     `gorm:"not null" json:"-"`
+		Nonce         string     `json:"-"`
+		AuthTime      time.Time  `json:"auth_time"`
+		FamilyID      string     `gorm:"not null" json:"family_id"`
+		IP            string     `json:"ip"`
+		UserAgent     string     `json:"user_agent"`
+		ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+		UsedAt        *time.Time `json:"used_at"`
+	}
+
+	// LoginFailure counts the failed logins for a username or a source IP
+	// since WindowStart. Keys are "user:<username>" and "ip:<address>", and
+	// are tracked whether or not the username exists.
+	LoginFailure struct {
+		Key         string     `gorm:"primarykey" json:"key"`
+		UpdatedAt   time.Time  `json:"updated_at"`
+		Failures    int        `gorm:"not null" json:"failures"`
+		WindowStart time.Time  `gorm:"not null;index" json:"window_start"`
+		LockedUntil *time.Time `json:"locked_until"`
+	}
+
+	// TOTPCredential is the authenticator app of a user. It only protects
+	// logins once a valid code has confirmed the enrolment. LastUsedStep keeps
+	// a code from being used twice.
+	TOTPCredential struct {
+		ID           uint       `gorm:"primarykey" json:"id"`
+		CreatedAt    time.Time  `json:"created_at"`
+		UpdatedAt    time.Time  `json:"updated_at"`
+		UserID       uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+		Secret       string     `gorm:"not null" json:"-"`
+		ConfirmedAt  *time.Time `json:"confirmed_at"`
+		LastUsedStep int64      `gorm:"not null;default:0" json:"-"`
+	}
+
+	// RecoveryCode is a single-use code that replaces a TOTP code when the
+	// authenticator app is lost. Only the SHA-256 hash of the code is stored.
+	RecoveryCode struct {
+		ID        uint       `gorm:"primarykey" json:"id"`
+		CreatedAt time.Time  `json:"created_at"`
+		UserID    uint       `gorm:"not null;index" json:"user_id"`
+		CodeHash  string     `gorm:"not null;uniqueIndex" json:"-"`
+		UsedAt    *time.Time `json:"used_at"`
+	}
+
+	// MFAChallenge is issued by the login endpoint once the password of a user
+	// with MFA has been checked, and exchanged for tokens with a second factor.
+	MFAChallenge struct {
+		ID        uint       `gorm:"primarykey" json:"id"`
+		CreatedAt time.Time  `json:"created_at"`
+		TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+		UserID    uint       `gorm:"not null" json:"user_id"`
+		Audience  string     `json:"audience"`
+		ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+		UsedAt    *time.Time `json:"used_at"`
+	}
+
+	// PasswordResetToken is a single-use token mailed to a user who forgot
+	// their password. Only the SHA-256 hash of the token is stored.
+	PasswordResetToken struct {
+		ID        uint       `gorm:"primarykey" json:"id"`
+		CreatedAt time.Time  `json:"created_at"`
+		TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+		UserID    uint       `gorm:"not null;index" json:"user_id"`
+		ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+		UsedAt    *time.Time `json:"used_at"`
+	}
+
+	// EmailVerificationToken proves that the user received mail at Email. It
+	// does not verify an address the user has changed to since.
+	EmailVerificationToken struct {
+		ID        uint       `gorm:"primarykey" json:"id"`
+		CreatedAt time.Time  `json:"created_at"`
+		TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+		UserID    uint       `gorm:"not null;index" json:"user_id"`
+		Email     string     `gorm:"not null" json:"email"`
+		ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+		UsedAt    *time.Time `json:"used_at"`
+	}
+
+	// MagicLink records a signed login link so it can be used only once. The
+	// link is void once the user's email address is no longer Email.
+	MagicLink struct {
+		ID        uint       `gorm:"primarykey" json:"id"`
+		CreatedAt time.Time  `json:"created_at"`
+		JTI       string     `gorm:"not null;uniqueIndex" json:"jti"`
+		UserID    uint       `gorm:"not null;index" json:"user_id"`
+		Email     string     `gorm:"not null" json:"email"`
+		Audience  string     `json:"audience"`
+		ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+		UsedAt    *time.Time `json:"used_at"`
+	}
+
+	// WebAuthnCredential is a passkey or security key of a user. SignCount is
+	// the last signature counter the authenticator reported. Assertions that
+	// do not advance it are refused, as they may come from a cloned key.
+	WebAuthnCredential struct {
+		ID              uint       `gorm:"primarykey" json:"id"`
+		CreatedAt       time.Time  `json:"created_at"`
+		UpdatedAt       time.Time  `json:"updated_at"`
+		UserID          uint       `gorm:"not null;index" json:"user_id"`
+		Name            string     `json:"name"`
+		CredentialID    []byte     `gorm:"not null;uniqueIndex" json:"credential_id"`
+		PublicKey       []byte     `gorm:"not null" json:"-"`
+		AttestationType string     `json:"attestation_type"`
+		AAGUID          []byte     `json:"aaguid"`
+		Transports      string     `json:"transports"`
+		SignCount       uint32     `gorm:"not null;default:0" json:"sign_count"`
+		BackupEligible  bool       `json:"backup_eligible"`
+		BackupState     bool       `json:"backup_state"`
+		LastUsedAt      *time.Time `json:"last_used_at"`
+	}
+
+	// WebAuthnChallenge is the server side of a registration or login
+	// ceremony. It is found by the challenge the client data echoes and used
+	// once. UserID is zero for logins that let the authenticator pick the
+	// account.
+	WebAuthnChallenge struct {
+		ID        uint       `gorm:"primarykey" json:"id"`
+		CreatedAt time.Time  `json:"created_at"`
+		Challenge string     `gorm:"not null;uniqueIndex" json:"-"`
+		Ceremony  string     `gorm:"not null" json:"ceremony"`
+		UserID    uint       `json:"user_id"`
+		Name      string     `json:"name"`
+		Audience  string     `json:"audience"`
+		Session   string     `gorm:"not null" json:"-"`
+		ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+		UsedAt    *time.Time `json:"used_at"`
+	}
+
+	SignupResponse struct {
+		ID                   uint   `json:"id"`
+		Username             string `json:"username"`
+		Email                string `json:"email"`
+		VerificationRequired bool   `json:"email_verification_required"`
+	}
+
+	MFAChallengeResponse struct {
+		MFAToken  string   `json:"mfa_token"`
+		ExpiresIn int64    `json:"expires_in"`
+		Methods   []string `json:"methods"`
+	}
+
+	TOTPEnrolment struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+	}
+
+	RecoveryCodesResponse struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	TokenResponse struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in"`
+		Scope        string `json:"scope,omitempty"`
+		IDToken      string `json:"id_token,omitempty"`
+	}
+
+	// UserInfo is the OpenID Connect UserInfo response. The profile claims are
+	// only returned for tokens with the profile scope.
+	UserInfo struct {
+		Sub               string `json:"sub"`
+		PreferredUsername string `json:"preferred_username,omitempty"`
+		UpdatedAt         int64  `json:"updated_at,omitempty"`
+	}
+
+	// OAuthError is the RFC 6749 error response of the token endpoint.
+	OAuthError struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}
+
+	// IntrospectionResponse is the RFC 7662 token introspection response.
+	IntrospectionResponse struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Username  string `json:"username,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+		Exp       int64  `json:"exp,omitempty"`
+		Iat       int64  `json:"iat,omitempty"`
+		Sub       string `json:"sub,omitempty"`
+		Aud       string `json:"aud,omitempty"`
+		Iss       string `json:"iss,omitempty"`
+		Jti       string `json:"jti,omitempty"`
+	}
+)
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"go-jwt/common/jwt"
+	"go-jwt/common/response"
+	"net/http"
+	"reflect"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *handler) ChangePassword(c *fiber.Ctx) error {
+
+	if _, errUser := currentUserID(c); errUser != nil {
+		return errUser
+	}
+	claims, _ := jwt.GetClaims(c)
+
+	var input ChangePasswordInput
+	if errParse := parseAndValidate(c, &input); errParse != nil {
+		return errParse
+	}
+
+	token, err := h.service.ChangePassword(claims, input.CurrentPassword, input.NewPassword, c.IP(), c.Get(fiber.HeaderUserAgent))
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully changed password", http.StatusOK, token))
+}
+
+func (h *handler) RequestPasswordReset(c *fiber.Ctx) error {
+
+	var input PasswordResetRequestInput
+	if errParse := parseAndValidate(c, &input); errParse != nil {
+		return errParse
+	}
+
+	if err := h.service.RequestPasswordReset(input.Email); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(response.BuildSuccessResponseMessage("if an account uses this email address, a password reset message has been sent to it", http.StatusAccepted, nil))
+}
+
+func (h *handler) ResetPassword(c *fiber.Ctx) error {
+
+	var input PasswordResetInput
+	if errParse := parseAndValidate(c, &input); errParse != nil {
+		return errParse
+	}
+
+	if err := h.service.ResetPassword(input.Token, input.NewPassword); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully reset password", http.StatusOK, nil))
+}
+
+func parseAndValidate(c *fiber.Ctx, input interface{}) error {
+
+	if err := c.BodyParser(input); err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Failed to parse request body",
+			Status:  "failed",
+			Code:    fiber.StatusUnprocessableEntity,
+			Errors:  err.Error(),
+		}
+	}
+
+	validation := response.ValidateBodyRequest(input)
+	if len(validation) != 0 {
+		return &response.FailedResponseMessage{
+			Message: "Failed request body",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  validation,
+		}
+	}
+
+	return nil
+}
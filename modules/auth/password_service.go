@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"errors"
+	"go-jwt/common/jwt"
+	"go-jwt/common/notify"
+	"go-jwt/common/response"
+	"go-jwt/modules/user"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultPasswordResetTTL = 30 * time.Minute
+
+// ChangePassword replaces the password of the signed in user once the current
+// one has been confirmed. Every other session is signed out; the caller gets
+// a fresh pair of tokens to carry on with.
+func (s *service) ChangePassword(claims *jwt.Claims, currentPassword, newPassword, ip, userAgent string) (TokenResponse, response.FailedResponseMessage) {
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Invalid token subject",
+			Status:  "failed",
+			Code:    http.StatusBadRequest,
+			Errors:  err.Error(),
+		}
+	}
+
+	found, err := s.userRepo.FindOneUserByID(uint(userID))
+	if err != nil {
+		return TokenResponse{}, mfaFailedResponse(err, "Failed to find user")
+	}
+
+	if _, errAuth := s.authenticate(found.Username, currentPassword, ip); !reflect.DeepEqual(errAuth, response.FailedResponseMessage{}) {
+		if errAuth.Code == http.StatusUnauthorized {
+			return TokenResponse{}, response.FailedResponseMessage{
+				Message: "Invalid current password",
+				Status:  "failed",
+				Code:    http.StatusBadRequest,
+				Errors:  "the current password is incorrect",
+			}
+		}
+		return TokenResponse{}, errAuth
+	}
+
+	if _, errChange := s.userService.ChangePassword(found.ID, newPassword); !reflect.DeepEqual(errChange, response.FailedResponseMessage{}) {
+		return TokenResponse{}, errChange
+	}
+
+	if errRevoke := s.RevokeUserTokens(found.ID); !reflect.DeepEqual(errRevoke, response.FailedResponseMessage{}) {
+		return TokenResponse{}, errRevoke
+	}
+
+	audience := ""
+	if len(claims.Audience) != 0 {
+		audience = claims.Audience[0]
+	}
+	return s.issueTokens(found, audience, ip, userAgent)
+}
+
+// RequestPasswordReset mails a reset token to every user with the given email
+// address. It succeeds whether or not there is one, so the endpoint cannot be
+// used to find out which addresses have accounts.
+func (s *service) RequestPasswordReset(email string) response.FailedResponseMessage {
+
+	users, err := s.userRepo.FindUsersByCriteria(user.User{Email: email})
+	if err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to find user",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	for _, found := range users {
+
+		token, tokenHash, err := newRefreshToken()
+		if err != nil {
+			return response.FailedResponseMessage{
+				Message: "Failed to generate reset token",
+				Status:  "failed",
+				Code:    http.StatusInternalServerError,
+				Errors:  err.Error(),
+			}
+		}
+
+		ttl := passwordResetTTL()
+		if _, err := s.authRepo.SavePasswordResetToken(PasswordResetToken{
+			TokenHash: tokenHash,
+			UserID:    found.ID,
+			ExpiresAt: time.Now().Add(ttl),
+		}); err != nil {
+			return response.FailedResponseMessage{
+				Message: "Failed to save reset token",
+				Status:  "failed",
+				Code:    http.StatusInternalServerError,
+				Errors:  err.Error(),
+			}
+		}
+
+		// Sent in the background so the response time does not tell whether
+		// an account exists.
+		message := passwordResetMessage(found, token, ttl)
+		go func(userID uint) {
+			if err := notify.Send(message); err != nil {
+				log.Printf("failed to send password reset to user %d: %v", userID, err)
+			}
+		}(found.ID)
+	}
+
+	return response.FailedResponseMessage{}
+}
+
+// ResetPassword sets a new password with a reset token. All reset tokens of
+// the user become invalid, and so do the tokens of every session.
+func (s *service) ResetPassword(token, newPassword string) response.FailedResponseMessage {
+
+	invalid := response.FailedResponseMessage{
+		Message: "Invalid reset token",
+		Status:  "failed",
+		Code:    http.StatusBadRequest,
+		Errors:  "the reset token is invalid or expired, please request a new one",
+	}
+
+	reset, err := s.authRepo.FindPasswordResetTokenByHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return invalid
+		}
+		return response.FailedResponseMessage{
+			Message: "Failed to find reset token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	if reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return invalid
+	}
+
+	// A password the policy refuses does not use up the token.
+	if errCheck := s.userService.CheckPassword(reset.UserID, newPassword); !reflect.DeepEqual(errCheck, response.FailedResponseMessage{}) {
+		return errCheck
+	}
+
+	used, err := s.authRepo.UsePasswordResetToken(reset.ID)
+	if err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to use reset token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	if !used {
+		return invalid
+	}
+
+	changed, errChange := s.userService.ChangePassword(reset.UserID, newPassword)
+	if !reflect.DeepEqual(errChange, response.FailedResponseMessage{}) {
+		return errChange
+	}
+
+	if err := s.authRepo.UsePasswordResetTokens(reset.UserID); err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to invalidate reset tokens",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	// Proving access to the mailbox is enough to lift a lockout.
+	if err := s.authRepo.DeleteLoginFailures(userLockoutKey(changed.Username)); err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to reset login attempts",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	return s.RevokeUserTokens(reset.UserID)
+}
+
+// passwordResetMessage links to PASSWORD_RESET_URL with the token when it is
+// set, and otherwise contains the bare token.
+func passwordResetMessage(found user.User, token string, ttl time.Duration) notify.Message {
+
+	instructions := "Use this token to choose a new password:\n\n" + token
+	if resetURL, err := url.Parse(os.Getenv("PASSWORD_RESET_URL")); err == nil && resetURL.String() != "" {
+		query := resetURL.Query()
+		query.Set("token", token)
+		resetURL.RawQuery = query.Encode()
+		instructions = "Open this link to choose a new password:\n\n" + resetURL.String()
+	}
+
+	return notify.Message{
+		To:      found.Email,
+		Subject: "Reset your password",
+		Body: "Hello " + found.Username + ",\n\n" +
+			"Someone asked to reset the password of your account. " + instructions + "\n\n" +
+			"It expires in " + ttl.String() + " and can only be used once. " +
+			"If you did not ask for this, you can ignore this message.",
+	}
+}
+
+func passwordResetTTL() time.Duration {
+	return durationFromEnv("PASSWORD_RESET_TTL", defaultPasswordResetTTL)
+}
@@ -0,0 +1,404 @@
+package auth
+
+import (
+	"go-jwt/common/response"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository interface {
+	SaveRefreshToken(token RefreshToken) (RefreshToken, error)
+	RotateRefreshToken(tokenHash string, next RefreshToken) (RefreshToken, error)
+	FindRefreshTokenByHash(tokenHash string) (RefreshToken, error)
+	RevokeRefreshTokenFamily(familyID string) error
+	RevokeRefreshTokensByUser(userID uint) error
+	SaveAuthorizationCode(code AuthorizationCode) (AuthorizationCode, error)
+	ConsumeAuthorizationCode(codeHash string) (AuthorizationCode, error)
+	FindLoginFailures(keys ...string) ([]LoginFailure, error)
+	RecordLoginFailure(key string, maxFailures int, window, lockout time.Duration) (LoginFailure, error)
+	DeleteLoginFailures(keys ...string) error
+	SavePasswordResetToken(token PasswordResetToken) (PasswordResetToken, error)
+	FindPasswordResetTokenByHash(tokenHash string) (PasswordResetToken, error)
+	UsePasswordResetToken(id uint) (bool, error)
+	UsePasswordResetTokens(userID uint) error
+	SaveEmailVerificationToken(token EmailVerificationToken) (EmailVerificationToken, error)
+	FindEmailVerificationTokenByHash(tokenHash string) (EmailVerificationToken, error)
+	UseEmailVerificationToken(id uint) (bool, error)
+	SaveMagicLink(link MagicLink) (MagicLink, error)
+	FindMagicLinkByJTI(jti string) (MagicLink, error)
+	UseMagicLink(id uint) (bool, error)
+	SaveWebAuthnCredential(credential WebAuthnCredential) (WebAuthnCredential, error)
+	FindWebAuthnCredentialsByUserID(userID uint) ([]WebAuthnCredential, error)
+	FindWebAuthnCredentialByCredentialID(credentialID []byte) (WebAuthnCredential, error)
+	UseWebAuthnCredential(id uint, signCount, nextSignCount uint32, backupState bool) (bool, error)
+	DeleteWebAuthnCredential(userID, id uint) (bool, error)
+	SaveWebAuthnChallenge(challenge WebAuthnChallenge) (WebAuthnChallenge, error)
+	FindWebAuthnChallenge(challenge string) (WebAuthnChallenge, error)
+	UseWebAuthnChallenge(id uint) (bool, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) SaveRefreshToken(token RefreshToken) (RefreshToken, error) {
+	if err := r.db.Create(&token).Error; err != nil {
+		return RefreshToken{}, err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken marks the token with the given hash as used and stores
+// next in the same family. Presenting a token that was already used or revoked
+// revokes the whole family, since it means the token has leaked; the token is
+// then returned with the error for the caller to end its session.
+func (r *repository) RotateRefreshToken(tokenHash string, next RefreshToken) (RefreshToken, error) {
+
+	var (
+		current RefreshToken
+		reused  bool
+	)
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where(&RefreshToken{TokenHash: tokenHash}).First(&current).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		if current.UsedAt != nil || current.RevokedAt != nil {
+			reused = true
+			return tx.Model(&RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", current.FamilyID).Update("revoked_at", now).Error
+		}
+
+		if now.After(current.ExpiresAt) {
+			return &response.FailedResponseMessage{
+				Message: "refresh token expired",
+				Status:  "failed",
+				Code:    fiber.StatusUnauthorized,
+				Errors:  "refresh token expired",
+			}
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		next.FamilyID = current.FamilyID
+		next.UserID = current.UserID
+		next.Audience = current.Audience
+		next.ClientID = current.ClientID
+		next.Scope = current.Scope
+		next.AuthTime = current.AuthTime
+		return tx.Create(&next).Error
+	})
+
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	if reused {
+		return current, &response.FailedResponseMessage{
+			Message: "refresh token reuse detected",
+			Status:  "failed",
+			Code:    fiber.StatusUnauthorized,
+			Errors:  "The refresh token has already been used. All tokens issued from it have been revoked, please log in again.",
+		}
+	}
+
+	return next, nil
+}
+
+func (r *repository) FindRefreshTokenByHash(tokenHash string) (RefreshToken, error) {
+	var token RefreshToken
+	if err := r.db.Where(&RefreshToken{TokenHash: tokenHash}).First(&token).Error; err != nil {
+		return RefreshToken{}, err
+	}
+	return token, nil
+}
+
+func (r *repository) RevokeRefreshTokenFamily(familyID string) error {
+	return r.db.Model(&RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", familyID).Update("revoked_at", time.Now()).Error
+}
+
+func (r *repository) RevokeRefreshTokensByUser(userID uint) error {
+	return r.db.Model(&RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", time.Now()).Error
+}
+
+func (r *repository) SaveAuthorizationCode(code AuthorizationCode) (AuthorizationCode, error) {
+	if err := r.db.Create(&code).Error; err != nil {
+		return AuthorizationCode{}, err
+	}
+	return code, nil
+}
+
+// ConsumeAuthorizationCode marks the code with the given hash as used. A code
+// that was already used has leaked, so the refresh tokens issued for it are
+// revoked, and the code is returned with the error for the caller to end its
+// session.
+func (r *repository) ConsumeAuthorizationCode(codeHash string) (AuthorizationCode, error) {
+
+	var code AuthorizationCode
+	var reused bool
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where(&AuthorizationCode{CodeHash: codeHash}).First(&code).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		if code.UsedAt != nil {
+			reused = true
+			return tx.Model(&RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", code.FamilyID).Update("revoked_at", now).Error
+		}
+
+		if now.After(code.ExpiresAt) {
+			return &response.FailedResponseMessage{
+				Message: "invalid_grant",
+				Status:  "failed",
+				Code:    fiber.StatusBadRequest,
+				Errors:  "authorization code expired",
+			}
+		}
+
+		return tx.Model(&code).Update("used_at", now).Error
+	})
+
+	if err != nil {
+		return AuthorizationCode{}, err
+	}
+
+	if reused {
+		return code, &response.FailedResponseMessage{
+			Message: "invalid_grant",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  "authorization code already used; the tokens issued for it have been revoked",
+		}
+	}
+
+	return code, nil
+}
+
+func (r *repository) FindLoginFailures(keys ...string) ([]LoginFailure, error) {
+	var failures []LoginFailure
+	if err := r.db.Where("key IN ?", keys).Find(&failures).Error; err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+// RecordLoginFailure counts a failed login for key. Failures older than window
+// are forgotten; reaching maxFailures locks the key for lockout and starts
+// counting again. Keys whose window and lock have both run out are deleted, so
+// guessing many usernames does not leave a row behind for each of them.
+func (r *repository) RecordLoginFailure(key string, maxFailures int, window, lockout time.Duration) (LoginFailure, error) {
+
+	var failure LoginFailure
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		now := time.Now()
+
+		if err := tx.Where("window_start < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-window), now).Delete(&LoginFailure{}).Error; err != nil {
+			return err
+		}
+
+		var existing []LoginFailure
+		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where(&LoginFailure{Key: key}).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		failure = LoginFailure{Key: key, WindowStart: now}
+		if len(existing) != 0 {
+			failure = existing[0]
+			if now.After(failure.WindowStart.Add(window)) {
+				failure.Failures = 0
+				failure.WindowStart = now
+			}
+		}
+
+		failure.Failures++
+		if failure.Failures >= maxFailures {
+			lockedUntil := now.Add(lockout)
+			failure.LockedUntil = &lockedUntil
+			failure.Failures = 0
+			failure.WindowStart = now
+		}
+
+		return tx.Save(&failure).Error
+	})
+
+	if err != nil {
+		return LoginFailure{}, err
+	}
+	return failure, nil
+}
+
+func (r *repository) DeleteLoginFailures(keys ...string) error {
+	return r.db.Where("key IN ?", keys).Delete(&LoginFailure{}).Error
+}
+
+func (r *repository) SavePasswordResetToken(token PasswordResetToken) (PasswordResetToken, error) {
+	if err := r.db.Create(&token).Error; err != nil {
+		return PasswordResetToken{}, err
+	}
+	return token, nil
+}
+
+func (r *repository) FindPasswordResetTokenByHash(tokenHash string) (PasswordResetToken, error) {
+	var token PasswordResetToken
+	if err := r.db.Where(&PasswordResetToken{TokenHash: tokenHash}).First(&token).Error; err != nil {
+		return PasswordResetToken{}, err
+	}
+	return token, nil
+}
+
+// UsePasswordResetToken marks the token as used and reports false when it
+// already was, so concurrent requests cannot both reset the password with it.
+func (r *repository) UsePasswordResetToken(id uint) (bool, error) {
+	result := r.db.Model(&PasswordResetToken{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UsePasswordResetTokens marks every outstanding reset token of the user as
+// used, so none of them survives a successful reset.
+func (r *repository) UsePasswordResetTokens(userID uint) error {
+	return r.db.Model(&PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", userID).Update("used_at", time.Now()).Error
+}
+
+func (r *repository) SaveEmailVerificationToken(token EmailVerificationToken) (EmailVerificationToken, error) {
+	if err := r.db.Create(&token).Error; err != nil {
+		return EmailVerificationToken{}, err
+	}
+	return token, nil
+}
+
+func (r *repository) FindEmailVerificationTokenByHash(tokenHash string) (EmailVerificationToken, error) {
+	var token EmailVerificationToken
+	if err := r.db.Where(&EmailVerificationToken{TokenHash: tokenHash}).First(&token).Error; err != nil {
+		return EmailVerificationToken{}, err
+	}
+	return token, nil
+}
+
+// UseEmailVerificationToken marks the token as used and reports false when it
+// already was.
+func (r *repository) UseEmailVerificationToken(id uint) (bool, error) {
+	result := r.db.Model(&EmailVerificationToken{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *repository) SaveMagicLink(link MagicLink) (MagicLink, error) {
+	if err := r.db.Create(&link).Error; err != nil {
+		return MagicLink{}, err
+	}
+	return link, nil
+}
+
+func (r *repository) FindMagicLinkByJTI(jti string) (MagicLink, error) {
+	var link MagicLink
+	if err := r.db.Where(&MagicLink{JTI: jti}).First(&link).Error; err != nil {
+		return MagicLink{}, err
+	}
+	return link, nil
+}
+
+// UseMagicLink marks the link as used and reports false when it already was,
+// so concurrent requests cannot both log in with it.
+func (r *repository) UseMagicLink(id uint) (bool, error) {
+	result := r.db.Model(&MagicLink{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *repository) SaveWebAuthnCredential(credential WebAuthnCredential) (WebAuthnCredential, error) {
+	if err := r.db.Create(&credential).Error; err != nil {
+		return WebAuthnCredential{}, err
+	}
+	return credential, nil
+}
+
+func (r *repository) FindWebAuthnCredentialsByUserID(userID uint) ([]WebAuthnCredential, error) {
+	var credentials []WebAuthnCredential
+	if err := r.db.Where(&WebAuthnCredential{UserID: userID}).Order("id").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func (r *repository) FindWebAuthnCredentialByCredentialID(credentialID []byte) (WebAuthnCredential, error) {
+	var credential WebAuthnCredential
+	if err := r.db.Where("credential_id = ?", credentialID).First(&credential).Error; err != nil {
+		return WebAuthnCredential{}, err
+	}
+	return credential, nil
+}
+
+// UseWebAuthnCredential stores the signature counter of an assertion and
+// reports false when another assertion has moved the counter since signCount
+// was read.
+func (r *repository) UseWebAuthnCredential(id uint, signCount, nextSignCount uint32, backupState bool) (bool, error) {
+	result := r.db.Model(&WebAuthnCredential{}).Where("id = ? AND sign_count = ?", id, signCount).Updates(map[string]interface{}{
+		"sign_count":   nextSignCount,
+		"backup_state": backupState,
+		"last_used_at": time.Now(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteWebAuthnCredential reports false when the user has no credential
+// with the given id.
+func (r *repository) DeleteWebAuthnCredential(userID, id uint) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&WebAuthnCredential{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *repository) SaveWebAuthnChallenge(challenge WebAuthnChallenge) (WebAuthnChallenge, error) {
+	if err := r.db.Create(&challenge).Error; err != nil {
+		return WebAuthnChallenge{}, err
+	}
+	return challenge, nil
+}
+
+func (r *repository) FindWebAuthnChallenge(challenge string) (WebAuthnChallenge, error) {
+	var found WebAuthnChallenge
+	if err := r.db.Where(&WebAuthnChallenge{Challenge: challenge}).First(&found).Error; err != nil {
+		return WebAuthnChallenge{}, err
+	}
+	return found, nil
+}
+
+// UseWebAuthnChallenge marks the challenge as used and reports false when it
+// already was, so one signed response cannot complete two ceremonies.
+func (r *repository) UseWebAuthnChallenge(id uint) (bool, error) {
+	result := r.db.Model(&WebAuthnChallenge{}).Where("id = ? AND used_at IS NULL", id).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
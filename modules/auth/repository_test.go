@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"errors"
+	"go-jwt/common/response"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens an in-memory database with the given models migrated. A
+// single connection keeps every query on the same database.
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+
+	now := time.Now()
+	earlier := now.Add(-time.Minute)
+
+	tests := []struct {
+		name  string
+		token *RefreshToken
+		// err is the message of the expected error, empty when the token rotates.
+		err           string
+		familyRevoked bool
+	}{
+		{"fresh", &RefreshToken{ExpiresAt: now.Add(time.Hour)}, "", false},
+		{"used", &RefreshToken{ExpiresAt: now.Add(time.Hour), UsedAt: &earlier}, "refresh token reuse detected", true},
+		{"revoked", &RefreshToken{ExpiresAt: now.Add(time.Hour), RevokedAt: &earlier}, "refresh token reuse detected", true},
+		{"expired", &RefreshToken{ExpiresAt: earlier}, "refresh token expired", false},
+		{"unknown", nil, gorm.ErrRecordNotFound.Error(), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			db := newTestDB(t, &RefreshToken{})
+			repo := NewRepository(db)
+
+			// Another token of the family shows whether the family was revoked.
+			sibling, err := repo.SaveRefreshToken(RefreshToken{FamilyID: "family", UserID: 1, TokenHash: "sibling", ExpiresAt: now.Add(time.Hour)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.token != nil {
+				test.token.FamilyID, test.token.UserID, test.token.TokenHash = "family", 1, "presented"
+				if _, err := repo.SaveRefreshToken(*test.token); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			next, err := repo.RotateRefreshToken("presented", RefreshToken{TokenHash: "next", ExpiresAt: now.Add(time.Hour)})
+
+			var responseErr *response.FailedResponseMessage
+			switch {
+			case test.err == "":
+				if err != nil {
+					t.Fatalf("RotateRefreshToken: %v", err)
+				}
+				if next.FamilyID != "family" || next.UserID != 1 {
+					t.Errorf("next token in family %q of user %d, want family of user 1", next.FamilyID, next.UserID)
+				}
+			case errors.As(err, &responseErr):
+				if responseErr.Message != test.err {
+					t.Fatalf("RotateRefreshToken error = %s, want %s", responseErr.Message, test.err)
+				}
+			case err == nil || err.Error() != test.err:
+				t.Fatalf("RotateRefreshToken error = %v, want %s", err, test.err)
+			}
+
+			var presented, stored RefreshToken
+			if test.token != nil {
+				if err := db.Where(&RefreshToken{TokenHash: "presented"}).First(&presented).Error; err != nil {
+					t.Fatal(err)
+				}
+				if test.err == "" && presented.UsedAt == nil {
+					t.Errorf("presented token not marked as used")
+				}
+			}
+			if err := db.First(&stored, sibling.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if revoked := stored.RevokedAt != nil; revoked != test.familyRevoked {
+				t.Errorf("family revoked = %v, want %v", revoked, test.familyRevoked)
+			}
+
+			var issued int64
+			if err := db.Model(&RefreshToken{}).Where(&RefreshToken{TokenHash: "next"}).Count(&issued).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored := issued == 1; stored != (test.err == "") {
+				t.Errorf("next token stored = %v, want %v", stored, test.err == "")
+			}
+		})
+	}
+}
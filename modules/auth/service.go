@@ -1,50 +1,187 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"go-jwt/common/jwt"
+	"go-jwt/common/password"
 	"go-jwt/common/response"
+	"go-jwt/modules/client"
 	"go-jwt/modules/role"
 	"go-jwt/modules/user"
 	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-webauthn/webauthn/protocol"
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+const defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+// OpenID Connect scopes. openid requests an ID token and access to the
+// UserInfo endpoint; profile adds the profile claims.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+)
+
 type Service interface {
-	Login(username, password string) (string, response.FailedResponseMessage)
-	VertifikasiToken(token string) response.FailedResponseMessage
+	Login(username, password, audience, ip, userAgent string) (TokenResponse, *MFAChallengeResponse, response.FailedResponseMessage)
+	VerifyMFA(mfaToken, code, ip, userAgent string) (TokenResponse, response.FailedResponseMessage)
+	Refresh(refreshToken, clientID, clientSecret string) (TokenResponse, response.FailedResponseMessage)
+	ClientCredentials(clientID, clientSecret, scope, audience string) (TokenResponse, response.FailedResponseMessage)
+	CheckAuthorizationRequest(input AuthorizeInput) (client.Client, response.FailedResponseMessage)
+	Authorize(input AuthorizeInput, ip, userAgent string) (string, response.FailedResponseMessage)
+	ExchangeAuthorizationCode(input TokenInput) (TokenResponse, response.FailedResponseMessage)
+	VertifikasiToken(token string) (*jwt.Claims, response.FailedResponseMessage)
+	Introspect(token, tokenTypeHint string) (IntrospectionResponse, response.FailedResponseMessage)
+	Logout(claims *jwt.Claims, refreshToken string) response.FailedResponseMessage
+	RevokeUserTokens(userID uint) response.FailedResponseMessage
+	Unlock(userID uint, ip string) response.FailedResponseMessage
+	UserInfo(claims *jwt.Claims) (UserInfo, response.FailedResponseMessage)
+	ChangePassword(claims *jwt.Claims, currentPassword, newPassword, ip, userAgent string) (TokenResponse, response.FailedResponseMessage)
+	RequestPasswordReset(email string) response.FailedResponseMessage
+	ResetPassword(token, newPassword string) response.FailedResponseMessage
+	Signup(input SignupInput) (SignupResponse, response.FailedResponseMessage)
+	VerifyEmail(token string) response.FailedResponseMessage
+	ResendVerification(email string) response.FailedResponseMessage
+	RequestMagicLink(email, audience string) response.FailedResponseMessage
+	LoginWithMagicLink(token, ip, userAgent string) (TokenResponse, *MFAChallengeResponse, response.FailedResponseMessage)
+	BeginWebAuthnRegistration(userID uint, name string) (*protocol.CredentialCreation, response.FailedResponseMessage)
+	FinishWebAuthnRegistration(userID uint, body []byte) (WebAuthnCredential, response.FailedResponseMessage)
+	ListWebAuthnCredentials(userID uint) ([]WebAuthnCredential, response.FailedResponseMessage)
+	DeleteWebAuthnCredential(userID, id uint) response.FailedResponseMessage
+	BeginWebAuthnLogin(username, audience string) (*protocol.CredentialAssertion, response.FailedResponseMessage)
+	FinishWebAuthnLogin(body []byte, ip, userAgent string) (TokenResponse, response.FailedResponseMessage)
+	ListSessions(userID uint, currentSessionID string) ([]jwt.Session, response.FailedResponseMessage)
+	RevokeSession(userID uint, sessionID string) response.FailedResponseMessage
 }
 
 type service struct {
-	userRepo user.Repository
-	roleRepo role.Repository
+	userRepo   user.Repository
+	roleRepo   role.Repository
+	authRepo   Repository
+	clientRepo client.Repository
+	mfaRepo    MFARepository
+	// userService applies the password policy to new passwords.
+	userService user.Service
 }
 
 // VertifikasiToken implements Service.
 
-func NewService(uRepo user.Repository, rRepo role.Repository) Service {
-	return &service{uRepo, rRepo}
+func NewService(uRepo user.Repository, rRepo role.Repository, aRepo Repository, cRepo client.Repository, mRepo MFARepository) Service {
+	return &service{uRepo, rRepo, aRepo, cRepo, mRepo, user.NewService(uRepo, rRepo)}
+}
+
+// Login checks the credentials and issues tokens, or returns an MFA challenge
+// instead when the user has enabled a second factor.
+func (s *service) Login(username string, password string, audience string, ip string, userAgent string) (TokenResponse, *MFAChallengeResponse, response.FailedResponseMessage) {
+
+	if audience != "" && !jwt.GetPolicy().Accepts(audience) {
+		return TokenResponse{}, nil, response.FailedResponseMessage{
+			Message: "Invalid audience",
+			Status:  "failed",
+			Code:    http.StatusBadRequest,
+			Errors:  "audience " + audience + " is not allowed",
+		}
+	}
+
+	user, errAuth := s.authenticate(username, password, ip)
+	if !reflect.DeepEqual(errAuth, response.FailedResponseMessage{}) {
+		return TokenResponse{}, nil, errAuth
+	}
+
+	challenge, errMFA := s.startMFAChallenge(user, audience)
+	if !reflect.DeepEqual(errMFA, response.FailedResponseMessage{}) {
+		return TokenResponse{}, nil, errMFA
+	}
+	if challenge != nil {
+		return TokenResponse{}, challenge, response.FailedResponseMessage{}
+	}
+
+	token, errIssue := s.issueTokens(user, audience, ip, userAgent)
+	return token, nil, errIssue
 }
 
-func (s *service) Login(username string, password string) (string, response.FailedResponseMessage) {
+// issueTokens starts a new session, and the refresh token family of the same
+// id, for a user who has signed in from ip with userAgent.
+func (s *service) issueTokens(user user.User, audience, ip, userAgent string) (TokenResponse, response.FailedResponseMessage) {
 
-	user, err := s.userRepo.FindUserOneUserByUsername(username)
+	session, errSession := startSession(uuid.NewString(), user.ID, "", ip, userAgent)
+	if !reflect.DeepEqual(errSession, response.FailedResponseMessage{}) {
+		return TokenResponse{}, errSession
+	}
+
+	claims := jwt.NewUserClaims(user.ID, user.Username, roleIDs(user.Roles), audience)
+	claims.SessionID = session.ID
+	accessToken, err := jwt.GenerateToken(claims)
+	if err != nil {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Failed to generate token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	refreshToken, tokenHash, err := newRefreshToken()
+	if err != nil {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Failed to generate refresh token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	if _, err := s.authRepo.SaveRefreshToken(RefreshToken{
+		FamilyID:  session.ID,
+		UserID:    user.ID,
+		Audience:  audience,
+		AuthTime:  time.Now(),
+		TokenHash: tokenHash,
+		ExpiresAt: session.ExpiresAt,
+	}); err != nil {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Failed to save refresh token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	return buildTokenResponse(accessToken, claims, refreshToken), response.FailedResponseMessage{}
+}
+
+// authenticate checks the username and password and returns the user. Failed
+// attempts are counted per username and source IP, and locked ones are
+// rejected before the password is checked.
+func (s *service) authenticate(username, plain, ip string) (user.User, response.FailedResponseMessage) {
+
+	if errLocked := checkLockout(s.authRepo, username, ip); !reflect.DeepEqual(errLocked, response.FailedResponseMessage{}) {
+		return user.User{}, errLocked
+	}
+
+	found, err := s.userRepo.FindUserOneUserByUsername(username)
 
 	if err != nil {
 
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", response.FailedResponseMessage{
-				Message: "Invalid username or password",
-				Status:  "failed",
-				Code:    http.StatusUnauthorized,
-				Errors:  err.Error(),
-			}
+			// Verify anyway so unknown usernames take as long as wrong passwords.
+			password.VerifyUnknown(plain)
+			return found, loginFailed(s.authRepo, username, ip)
 		}
 
-		return "", response.FailedResponseMessage{
+		return found, response.FailedResponseMessage{
 			Message: "failed to find username",
 			Status:  "failed",
 			Code:    http.StatusInternalServerError,
@@ -52,68 +189,252 @@ func (s *service) Login(username string, password string) (string, response.Fail
 		}
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+	match, rehash, err := password.Verify(found.Password, plain)
+	if err != nil {
+		return found, response.FailedResponseMessage{
+			Message: "Failed to verify password",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	if !match {
+		return found, loginFailed(s.authRepo, username, ip)
+	}
+
+	// The password is only ever available here, so this is where hashes move
+	// to the preferred algorithm and parameters. A failed upgrade is retried
+	// on the next login.
+	if rehash {
+		if upgraded, err := password.Hash(plain); err == nil {
+			if err := s.userRepo.RehashPassword(found.ID, found.Password, upgraded); err == nil {
+				found.Password = upgraded
+			}
+		}
+	}
+
+	if err := s.authRepo.DeleteLoginFailures(userLockoutKey(username)); err != nil {
+		return found, response.FailedResponseMessage{
+			Message: "Failed to reset login attempts",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	return found, checkEmailVerified(found)
+}
 
-		if err == bcrypt.ErrMismatchedHashAndPassword {
-			return "", response.FailedResponseMessage{
-				Message: "Invalid username or password",
+// Refresh rotates the refresh token. Tokens issued to a client are only
+// redeemed by that client, authenticated like for the other grants; clientID
+// is empty for tokens issued by the login endpoints.
+func (s *service) Refresh(refreshToken, clientID, clientSecret string) (TokenResponse, response.FailedResponseMessage) {
+
+	if clientID != "" {
+		if _, errClient := s.authenticateClient(clientID, clientSecret); !reflect.DeepEqual(errClient, response.FailedResponseMessage{}) {
+			return TokenResponse{}, errClient
+		}
+	}
+
+	current, err := s.authRepo.FindRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Failed to find refresh token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	if err == nil && current.ClientID != clientID {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Invalid refresh token",
+			Status:  "failed",
+			Code:    http.StatusUnauthorized,
+			Errors:  "the refresh token was not issued to this client",
+		}
+	}
+
+	nextToken, nextHash, err := newRefreshToken()
+	if err != nil {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Failed to generate refresh token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	rotated, err := s.authRepo.RotateRefreshToken(hashToken(refreshToken), RefreshToken{
+		TokenHash: nextHash,
+		ExpiresAt: time.Now().Add(refreshTokenTTL()),
+	})
+	if err != nil {
+		var responseErr *response.FailedResponseMessage
+		if errors.As(err, &responseErr) {
+			// The access tokens of a replayed refresh token die with its session.
+			if rotated.FamilyID != "" {
+				if errSession := s.RevokeSession(rotated.UserID, rotated.FamilyID); errSession.Code == http.StatusInternalServerError {
+					return TokenResponse{}, errSession
+				}
+			}
+			return TokenResponse{}, *responseErr
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			return TokenResponse{}, response.FailedResponseMessage{
+				Message: "Invalid refresh token",
 				Status:  "failed",
 				Code:    http.StatusUnauthorized,
-				Errors:  "Invalid username or password",
+				Errors:  "Invalid refresh token",
 			}
 		}
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Failed to rotate refresh token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
 
-		return "", response.FailedResponseMessage{
-			Message: "Role not found",
+	user, err := s.userRepo.FindOneUserByID(rotated.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return TokenResponse{}, response.FailedResponseMessage{
+				Message: "Invalid refresh token",
+				Status:  "failed",
+				Code:    http.StatusUnauthorized,
+				Errors:  "user no longer exists",
+			}
+		}
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "failed to find user",
 			Status:  "failed",
-			Code:    http.StatusBadRequest,
+			Code:    http.StatusInternalServerError,
 			Errors:  err.Error(),
 		}
 	}
 
-	role, err := s.roleRepo.FindOneRoleByID(user.RoleID)
+	claims := jwt.NewUserClaims(user.ID, user.Username, roleIDs(user.Roles), rotated.Audience)
+	claims.ClientID = rotated.ClientID
+	claims.Scope = rotated.Scope
+
+	// Families started before sessions were recorded have none, and their
+	// tokens carry no sid.
+	session, err := jwt.GetSessionStore().Touch(rotated.FamilyID, rotated.ExpiresAt)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	case err != nil:
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Failed to update session",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	case session.RevokedAt != nil:
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Invalid refresh token",
+			Status:  "failed",
+			Code:    http.StatusUnauthorized,
+			Errors:  "the session has been revoked",
+		}
+	default:
+		claims.SessionID = session.ID
+	}
+	accessToken, err := jwt.GenerateToken(claims)
 	if err != nil {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Failed to generate token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
 
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", response.FailedResponseMessage{
-				Message: "Role not found",
+	token := buildTokenResponse(accessToken, claims, nextToken)
+	token.Scope = claims.Scope
+
+	// A refreshed ID token keeps the original auth_time but has no nonce.
+	if contains(claims.Scopes(), ScopeOpenID) {
+		token.IDToken, err = jwt.GenerateIDToken(claims.Subject, claims.ClientID, "", rotated.AuthTime, accessToken)
+		if err != nil {
+			return TokenResponse{}, response.FailedResponseMessage{
+				Message: "Failed to generate ID token",
 				Status:  "failed",
-				Code:    http.StatusBadRequest,
+				Code:    http.StatusInternalServerError,
 				Errors:  err.Error(),
 			}
 		}
+	}
 
-		return "", response.FailedResponseMessage{
-			Message: "Failed to find Role",
+	return token, response.FailedResponseMessage{}
+}
+
+// ClientCredentials implements the RFC 6749 client credentials grant. Error
+// messages are the OAuth error codes.
+func (s *service) ClientCredentials(clientID, clientSecret, scope, audience string) (TokenResponse, response.FailedResponseMessage) {
+
+	registered, err := s.clientRepo.FindOneClientByClientID(clientID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "server_error",
 			Status:  "failed",
 			Code:    http.StatusInternalServerError,
 			Errors:  err.Error(),
 		}
 	}
+	if err != nil || !registered.VerifySecret(clientSecret) {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "invalid_client",
+			Status:  "failed",
+			Code:    http.StatusUnauthorized,
+			Errors:  "client authentication failed",
+		}
+	}
+
+	if registered.Public {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "unauthorized_client",
+			Status:  "failed",
+			Code:    http.StatusBadRequest,
+			Errors:  "public clients may not use the client credentials grant",
+		}
+	}
+
+	scopes, errScope := resolveScopes(registered, scope)
+	if !reflect.DeepEqual(errScope, response.FailedResponseMessage{}) {
+		return TokenResponse{}, errScope
+	}
+
+	audience, errAudience := resolveAudience(registered, audience)
+	if !reflect.DeepEqual(errAudience, response.FailedResponseMessage{}) {
+		return TokenResponse{}, errAudience
+	}
 
-	token, err := jwt.GenerateToken(user.Username, role.ID)
+	claims := jwt.NewClientClaims(registered.ClientID, scopes, audience)
+	accessToken, err := jwt.GenerateToken(claims)
 	if err != nil {
-		return "", response.FailedResponseMessage{
-			Message: "Failed to generate token",
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "server_error",
 			Status:  "failed",
 			Code:    http.StatusInternalServerError,
 			Errors:  err.Error(),
 		}
 	}
+
+	token := buildTokenResponse(accessToken, claims, "")
+	token.Scope = claims.Scope
 	return token, response.FailedResponseMessage{}
 }
 
-func (s *service) VertifikasiToken(token string) response.FailedResponseMessage {
+func (s *service) VertifikasiToken(token string) (*jwt.Claims, response.FailedResponseMessage) {
 
-	_, err := jwt.VerifyToken(token)
+	claims, err := jwt.VerifyToken(token)
 	if err != nil {
 
 		var responseMessageFailed *response.FailedResponseMessage
 		if errors.As(err, &responseMessageFailed) {
-			return *responseMessageFailed
+			return nil, *responseMessageFailed
 		}
 
-		return response.FailedResponseMessage{
+		return nil, response.FailedResponseMessage{
 			Message: "Failed to Verify token",
 			Status:  "failed",
 			Code:    fiber.StatusInternalServerError,
@@ -121,5 +442,323 @@ func (s *service) VertifikasiToken(token string) response.FailedResponseMessage
 		}
 	}
 
+	return claims, response.FailedResponseMessage{}
+}
+
+// Introspect describes token in the RFC 7662 format. Tokens that fail
+// verification, including revoked ones, are reported as inactive.
+func (s *service) Introspect(token, tokenTypeHint string) (IntrospectionResponse, response.FailedResponseMessage) {
+
+	if tokenTypeHint == "refresh_token" {
+		result, err := s.introspectRefreshToken(token)
+		if err != nil {
+			return IntrospectionResponse{}, response.FailedResponseMessage{
+				Message: "Failed to introspect token",
+				Status:  "failed",
+				Code:    http.StatusInternalServerError,
+				Errors:  err.Error(),
+			}
+		}
+		if result.Active {
+			return result, response.FailedResponseMessage{}
+		}
+	}
+
+	claims, errVerify := s.VertifikasiToken(token)
+	if !reflect.DeepEqual(errVerify, response.FailedResponseMessage{}) {
+		if errVerify.Code == http.StatusUnauthorized {
+			return IntrospectionResponse{Active: false}, response.FailedResponseMessage{}
+		}
+		return IntrospectionResponse{}, errVerify
+	}
+
+	return IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Username:  claims.Username,
+		TokenType: "Bearer",
+		Exp:       claims.ExpiresAt.Unix(),
+		Iat:       claims.IssuedAt.Unix(),
+		Sub:       claims.Subject,
+		Aud:       strings.Join(claims.Audience, " "),
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+	}, response.FailedResponseMessage{}
+}
+
+func (s *service) introspectRefreshToken(token string) (IntrospectionResponse, error) {
+
+	refreshToken, err := s.authRepo.FindRefreshTokenByHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return IntrospectionResponse{Active: false}, nil
+		}
+		return IntrospectionResponse{}, err
+	}
+
+	if refreshToken.UsedAt != nil || refreshToken.RevokedAt != nil || time.Now().After(refreshToken.ExpiresAt) {
+		return IntrospectionResponse{Active: false}, nil
+	}
+
+	user, err := s.userRepo.FindOneUserByID(refreshToken.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return IntrospectionResponse{Active: false}, nil
+		}
+		return IntrospectionResponse{}, err
+	}
+
+	return IntrospectionResponse{
+		Active:    true,
+		Username:  user.Username,
+		Sub:       strconv.FormatUint(uint64(user.ID), 10),
+		TokenType: "refresh_token",
+		Iss:       jwt.GetPolicy().Issuer,
+		Exp:       refreshToken.ExpiresAt.Unix(),
+		Iat:       refreshToken.CreatedAt.Unix(),
+	}, nil
+}
+
+// Logout revokes the access token and, when given, the refresh token family it belongs to.
+func (s *service) Logout(claims *jwt.Claims, refreshToken string) response.FailedResponseMessage {
+
+	if err := jwt.RevokeToken(claims); err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to revoke token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	// Logging out ends the session, and with it the refresh tokens of the
+	// session, whether or not one is given.
+	if claims.SessionID != "" {
+		userID, _ := strconv.ParseUint(claims.Subject, 10, 32)
+		if errSession := s.RevokeSession(uint(userID), claims.SessionID); errSession.Code == http.StatusInternalServerError {
+			return errSession
+		}
+	}
+
+	if refreshToken == "" {
+		return response.FailedResponseMessage{}
+	}
+
+	token, err := s.authRepo.FindRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.FailedResponseMessage{}
+		}
+		return response.FailedResponseMessage{
+			Message: "Failed to find refresh token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	user, err := s.userRepo.FindUserOneUserByUsername(claims.Username)
+	if err != nil || user.ID != token.UserID {
+		return response.FailedResponseMessage{}
+	}
+
+	if err := s.authRepo.RevokeRefreshTokenFamily(token.FamilyID); err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to revoke refresh token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	return response.FailedResponseMessage{}
+}
+
+// RevokeUserTokens invalidates every access and refresh token issued to the user so far
+// and ends their sessions.
+func (s *service) RevokeUserTokens(userID uint) response.FailedResponseMessage {
+
+	user, err := s.userRepo.FindOneUserByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.FailedResponseMessage{
+				Message: "User not found",
+				Status:  "failed",
+				Code:    http.StatusNotFound,
+				Errors:  err.Error(),
+			}
+		}
+		return response.FailedResponseMessage{
+			Message: "Failed to find user",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	if err := jwt.GetRevocationStore().RevokeAllForUser(user.Username); err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to revoke tokens",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	if err := s.authRepo.RevokeRefreshTokensByUser(user.ID); err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to revoke refresh tokens",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	if err := jwt.GetSessionStore().RevokeAllForUser(user.ID); err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to revoke sessions",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
 	return response.FailedResponseMessage{}
 }
+
+// UserInfo returns the claims about the user the token was issued to. The
+// token must have been issued with the openid scope.
+func (s *service) UserInfo(claims *jwt.Claims) (UserInfo, response.FailedResponseMessage) {
+
+	if claims.IsClientToken() || !contains(claims.Scopes(), ScopeOpenID) {
+		return UserInfo{}, response.FailedResponseMessage{
+			Message: "insufficient_scope",
+			Status:  "failed",
+			Code:    http.StatusForbidden,
+			Errors:  "the token was not issued with the openid scope",
+		}
+	}
+
+	id, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil {
+		return UserInfo{}, response.FailedResponseMessage{
+			Message: "invalid_token",
+			Status:  "failed",
+			Code:    http.StatusUnauthorized,
+			Errors:  err.Error(),
+		}
+	}
+
+	user, err := s.userRepo.FindOneUserByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return UserInfo{}, response.FailedResponseMessage{
+				Message: "invalid_token",
+				Status:  "failed",
+				Code:    http.StatusUnauthorized,
+				Errors:  "user no longer exists",
+			}
+		}
+		return UserInfo{}, response.FailedResponseMessage{
+			Message: "server_error",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	info := UserInfo{Sub: claims.Subject}
+	if contains(claims.Scopes(), ScopeProfile) {
+		info.PreferredUsername = user.Username
+		info.UpdatedAt = user.UpdatedAt.Unix()
+	}
+	return info, response.FailedResponseMessage{}
+}
+
+func buildTokenResponse(accessToken string, claims *jwt.Claims, refreshToken string) TokenResponse {
+	return TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(claims.ExpiresAt.Sub(claims.IssuedAt.Time).Seconds()),
+	}
+}
+
+// newRefreshToken returns a random opaque token and the hash to persist for it.
+func newRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func refreshTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultRefreshTokenTTL
+}
+
+func roleIDs(roles []role.Role) []uint {
+	ids := []uint{}
+	for _, role := range roles {
+		ids = append(ids, role.ID)
+	}
+	return ids
+}
+
+// resolveScopes checks the requested scopes against those of the client. An
+// empty scope requests every scope the client is allowed.
+func resolveScopes(registered client.Client, scope string) ([]string, response.FailedResponseMessage) {
+
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		scopes = registered.Scopes()
+	}
+	for _, requested := range scopes {
+		if !contains(registered.Scopes(), requested) {
+			return nil, response.FailedResponseMessage{
+				Message: "invalid_scope",
+				Status:  "failed",
+				Code:    http.StatusBadRequest,
+				Errors:  "scope " + requested + " is not allowed for this client",
+			}
+		}
+	}
+	return scopes, response.FailedResponseMessage{}
+}
+
+// resolveAudience checks the requested audience against those of the client.
+// An empty audience selects the client's first audience.
+func resolveAudience(registered client.Client, audience string) (string, response.FailedResponseMessage) {
+
+	if audience == "" && len(registered.Audiences()) != 0 {
+		audience = registered.Audiences()[0]
+	}
+	if audience != "" && (!contains(registered.Audiences(), audience) || !jwt.GetPolicy().Accepts(audience)) {
+		return "", response.FailedResponseMessage{
+			Message: "invalid_target",
+			Status:  "failed",
+			Code:    http.StatusBadRequest,
+			Errors:  "audience " + audience + " is not allowed for this client",
+		}
+	}
+	return audience, response.FailedResponseMessage{}
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"go-jwt/modules/client"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRefreshClientBinding(t *testing.T) {
+
+	db := newTestDB(t, &RefreshToken{}, &client.Client{})
+	s := &service{authRepo: NewRepository(db), clientRepo: client.NewRepository(db)}
+
+	secretHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, clientID := range []string{"app", "other"} {
+		if err := db.Create(&client.Client{ClientID: clientID, Name: clientID, SecretHash: string(secretHash), Version: 1}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expires := time.Now().Add(time.Hour)
+	tokens := []RefreshToken{
+		{FamilyID: "app", UserID: 1, ClientID: "app", TokenHash: hashToken("app-token"), ExpiresAt: expires},
+		{FamilyID: "login", UserID: 1, TokenHash: hashToken("login-token"), ExpiresAt: expires},
+	}
+	for _, token := range tokens {
+		if _, err := s.authRepo.SaveRefreshToken(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		refreshToken string
+		clientID     string
+		clientSecret string
+		message      string
+	}{
+		{"another client", "app-token", "other", "secret", "Invalid refresh token"},
+		{"no client", "app-token", "", "", "Invalid refresh token"},
+		{"wrong secret", "app-token", "app", "wrong", "invalid_client"},
+		{"unknown client", "app-token", "unknown", "secret", "invalid_client"},
+		{"login token presented by a client", "login-token", "app", "secret", "Invalid refresh token"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			_, err := s.Refresh(test.refreshToken, test.clientID, test.clientSecret)
+			if err.Code != http.StatusUnauthorized || err.Message != test.message {
+				t.Fatalf("Refresh = %d %s, want %d %s", err.Code, err.Message, http.StatusUnauthorized, test.message)
+			}
+
+			// A refused token stays usable by the client it was issued to.
+			presented, errFind := s.authRepo.FindRefreshTokenByHash(hashToken(test.refreshToken))
+			if errFind != nil {
+				t.Fatal(errFind)
+			}
+			if presented.UsedAt != nil || presented.RevokedAt != nil {
+				t.Errorf("refused token was used or revoked")
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"go-jwt/common/jwt"
+	"go-jwt/common/response"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *handler) ListSessions(c *fiber.Ctx) error {
+
+	userID, errUser := currentUserID(c)
+	if errUser != nil {
+		return errUser
+	}
+
+	claims, _ := jwt.GetClaims(c)
+	sessions, err := h.service.ListSessions(userID, claims.SessionID)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find sessions", http.StatusOK, sessions))
+}
+
+func (h *handler) RevokeSession(c *fiber.Ctx) error {
+
+	userID, errUser := currentUserID(c)
+	if errUser != nil {
+		return errUser
+	}
+
+	if err := h.service.RevokeSession(userID, c.Params("id")); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully revoked session", http.StatusOK, nil))
+}
+
+func (h *handler) ListUserSessions(c *fiber.Ctx) error {
+
+	id, errID := strconv.ParseUint(c.Params("id"), 10, 32)
+	if errID != nil {
+		return &response.FailedResponseMessage{
+			Message: "Invalid Convert ID",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  errID.Error(),
+		}
+	}
+
+	claims, _ := jwt.GetClaims(c)
+	sessions, err := h.service.ListSessions(uint(id), claims.SessionID)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find user sessions", http.StatusOK, sessions))
+}
+
+func (h *handler) RevokeUserSession(c *fiber.Ctx) error {
+
+	id, errID := strconv.ParseUint(c.Params("id"), 10, 32)
+	if errID != nil {
+		return &response.FailedResponseMessage{
+			Message: "Invalid Convert ID",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  errID.Error(),
+		}
+	}
+
+	if err := h.service.RevokeSession(uint(id), c.Params("sid")); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully revoked user session", http.StatusOK, nil))
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"go-jwt/common/jwt"
+	"go-jwt/common/response"
+	"net/http"
+	"time"
+)
+
+// ListSessions returns the active sessions of the user. The one with the id
+// currentSessionID, if any, is marked as current.
+func (s *service) ListSessions(userID uint, currentSessionID string) ([]jwt.Session, response.FailedResponseMessage) {
+
+	if _, err := s.userRepo.FindOneUserByID(userID); err != nil {
+		return nil, mfaFailedResponse(err, "Failed to find user")
+	}
+
+	sessions, err := jwt.GetSessionStore().FindActive(userID)
+	if err != nil {
+		return nil, response.FailedResponseMessage{
+			Message: "Failed to find sessions",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, response.FailedResponseMessage{}
+}
+
+// RevokeSession signs the user out of one session. Its refresh tokens stop
+// working at once and its access tokens as soon as every instance notices.
+func (s *service) RevokeSession(userID uint, sessionID string) response.FailedResponseMessage {
+
+	revoked, err := jwt.GetSessionStore().Revoke(userID, sessionID)
+	if err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to revoke session",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	if !revoked {
+		return response.FailedResponseMessage{
+			Message: "Session not found",
+			Status:  "failed",
+			Code:    http.StatusNotFound,
+			Errors:  "no active session with id " + sessionID,
+		}
+	}
+
+	if err := s.authRepo.RevokeRefreshTokenFamily(sessionID); err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to revoke refresh tokens",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	return response.FailedResponseMessage{}
+}
+
+// startSession records a sign-in. The session lasts as long as a refresh
+// token, and every refresh extends it.
+func startSession(id string, userID uint, clientID, ip, userAgent string) (jwt.Session, response.FailedResponseMessage) {
+
+	now := time.Now()
+	session, err := jwt.GetSessionStore().Create(jwt.Session{
+		ID:         id,
+		UserID:     userID,
+		ClientID:   clientID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL()),
+	})
+	if err != nil {
+		return jwt.Session{}, response.FailedResponseMessage{
+			Message: "Failed to save session",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	return session, response.FailedResponseMessage{}
+}
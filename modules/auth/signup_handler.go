@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"go-jwt/common/response"
+	"net/http"
+	"reflect"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *handler) Signup(c *fiber.Ctx) error {
+
+	var input SignupInput
+	if errParse := parseAndValidate(c, &input); errParse != nil {
+		return errParse
+	}
+
+	account, err := h.service.Signup(input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response.BuildSuccessResponseMessage("successfully signed up, a verification message has been sent to your email address", http.StatusCreated, account))
+}
+
+func (h *handler) VerifyEmail(c *fiber.Ctx) error {
+
+	var input VerifyEmailInput
+	if errParse := parseAndValidate(c, &input); errParse != nil {
+		return errParse
+	}
+
+	if err := h.service.VerifyEmail(input.Token); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully verified email address", http.StatusOK, nil))
+}
+
+func (h *handler) ResendVerification(c *fiber.Ctx) error {
+
+	var input VerificationRequestInput
+	if errParse := parseAndValidate(c, &input); errParse != nil {
+		return errParse
+	}
+
+	if err := h.service.ResendVerification(input.Email); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(response.BuildSuccessResponseMessage("if an unverified account uses this email address, a verification message has been sent to it", http.StatusAccepted, nil))
+}
@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"errors"
+	"go-jwt/common/notify"
+	"go-jwt/common/response"
+	"go-jwt/modules/user"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSignupRole           = "user"
+	defaultEmailVerificationTTL = 24 * time.Hour
+)
+
+// Signup creates an account with the default role for anyone who asks, when
+// SIGNUP_ENABLED is set, and mails a link to verify the email address.
+func (s *service) Signup(input SignupInput) (SignupResponse, response.FailedResponseMessage) {
+
+	if !boolFromEnv("SIGNUP_ENABLED", false) {
+		return SignupResponse{}, response.FailedResponseMessage{
+			Message: "Sign-up is disabled",
+			Status:  "failed",
+			Code:    http.StatusForbidden,
+			Errors:  "accounts can only be created by an administrator",
+		}
+	}
+
+	roleName := os.Getenv("SIGNUP_DEFAULT_ROLE")
+	if roleName == "" {
+		roleName = defaultSignupRole
+	}
+	defaultRole, err := s.roleRepo.FindOneRoleByName(roleName)
+	if err != nil {
+		return SignupResponse{}, response.FailedResponseMessage{
+			Message: "Failed to find the default role",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  "role " + roleName + ": " + err.Error(),
+		}
+	}
+
+	created, errSave := s.userService.Save(user.RegisterInputUser{
+		Username: input.Username,
+		Email:    input.Email,
+		Password: input.Password,
+		RoleIDs:  []uint{defaultRole.ID},
+	})
+	if !reflect.DeepEqual(errSave, response.FailedResponseMessage{}) {
+		return SignupResponse{}, errSave
+	}
+
+	if errSend := s.sendVerification(created); !reflect.DeepEqual(errSend, response.FailedResponseMessage{}) {
+		return SignupResponse{}, errSend
+	}
+
+	return SignupResponse{
+		ID:                   created.ID,
+		Username:             created.Username,
+		Email:                created.Email,
+		VerificationRequired: emailVerificationRequired(),
+	}, response.FailedResponseMessage{}
+}
+
+// VerifyEmail marks the address a verification token was sent to as verified.
+func (s *service) VerifyEmail(token string) response.FailedResponseMessage {
+
+	invalid := response.FailedResponseMessage{
+		Message: "Invalid verification token",
+		Status:  "failed",
+		Code:    http.StatusBadRequest,
+		Errors:  "the verification token is invalid or expired, please request a new one",
+	}
+
+	verification, err := s.authRepo.FindEmailVerificationTokenByHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return invalid
+		}
+		return response.FailedResponseMessage{
+			Message: "Failed to find verification token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	if verification.UsedAt != nil || time.Now().After(verification.ExpiresAt) {
+		return invalid
+	}
+
+	used, err := s.authRepo.UseEmailVerificationToken(verification.ID)
+	if err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to use verification token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	if !used {
+		return invalid
+	}
+
+	verified, err := s.userRepo.VerifyEmail(verification.UserID, verification.Email)
+	if err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to verify email",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	if !verified {
+		found, err := s.userRepo.FindOneUserByID(verification.UserID)
+		if err != nil || found.Email != verification.Email {
+			return invalid
+		}
+	}
+	return response.FailedResponseMessage{}
+}
+
+// ResendVerification mails a new verification link to the unverified
+// accounts using email. Like the password reset request, it succeeds whether
+// or not there are any.
+func (s *service) ResendVerification(email string) response.FailedResponseMessage {
+
+	users, err := s.userRepo.FindUsersByCriteria(user.User{Email: email})
+	if err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to find user",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	for _, found := range users {
+		if found.EmailVerifiedAt != nil {
+			continue
+		}
+		if errSend := s.sendVerification(found); !reflect.DeepEqual(errSend, response.FailedResponseMessage{}) {
+			return errSend
+		}
+	}
+	return response.FailedResponseMessage{}
+}
+
+// sendVerification issues a verification token for the current email address
+// of the user and mails it in the background.
+func (s *service) sendVerification(found user.User) response.FailedResponseMessage {
+
+	token, tokenHash, err := newRefreshToken()
+	if err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to generate verification token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	ttl := durationFromEnv("EMAIL_VERIFICATION_TTL", defaultEmailVerificationTTL)
+	if _, err := s.authRepo.SaveEmailVerificationToken(EmailVerificationToken{
+		TokenHash: tokenHash,
+		UserID:    found.ID,
+		Email:     found.Email,
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to save verification token",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	message := verificationMessage(found, token, ttl)
+	go func(userID uint) {
+		if err := notify.Send(message); err != nil {
+			log.Printf("failed to send email verification to user %d: %v", userID, err)
+		}
+	}(found.ID)
+
+	return response.FailedResponseMessage{}
+}
+
+// verificationMessage links to EMAIL_VERIFICATION_URL with the token when it
+// is set, and otherwise contains the bare token.
+func verificationMessage(found user.User, token string, ttl time.Duration) notify.Message {
+
+	instructions := "Use this token to verify it:\n\n" + token
+	if verifyURL, err := url.Parse(os.Getenv("EMAIL_VERIFICATION_URL")); err == nil && verifyURL.String() != "" {
+		query := verifyURL.Query()
+		query.Set("token", token)
+		verifyURL.RawQuery = query.Encode()
+		instructions = "Open this link to verify it:\n\n" + verifyURL.String()
+	}
+
+	return notify.Message{
+		To:      found.Email,
+		Subject: "Verify your email address",
+		Body: "Hello " + found.Username + ",\n\n" +
+			"This address was given for your account. " + instructions + "\n\n" +
+			"It expires in " + ttl.String() + ". " +
+			"If you did not sign up, you can ignore this message.",
+	}
+}
+
+// checkEmailVerified refuses users who have to verify their email address
+// before they may log in.
+func checkEmailVerified(found user.User) response.FailedResponseMessage {
+	if emailVerificationRequired() && found.Email != "" && found.EmailVerifiedAt == nil {
+		return response.FailedResponseMessage{
+			Message: "Email address not verified",
+			Status:  "failed",
+			Code:    http.StatusForbidden,
+			Errors:  "verify your email address with the link sent to it before logging in",
+		}
+	}
+	return response.FailedResponseMessage{}
+}
+
+// emailVerificationRequired is read from EMAIL_VERIFICATION_REQUIRED. When
+// set, users with an unverified email address cannot log in. Users without
+// an email address, which only administrators can create, are not affected.
+func emailVerificationRequired() bool {
+	return boolFromEnv("EMAIL_VERIFICATION_REQUIRED", false)
+}
+
+func boolFromEnv(name string, fallback bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(name)); err == nil {
+		return value
+	}
+	return fallback
+}
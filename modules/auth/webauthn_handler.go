@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"go-jwt/common/response"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *handler) BeginWebAuthnRegistration(c *fiber.Ctx) error {
+
+	userID, errUser := currentUserID(c)
+	if errUser != nil {
+		return errUser
+	}
+
+	var input WebAuthnRegistrationInput
+	if len(c.Body()) != 0 {
+		if errParse := parseAndValidate(c, &input); errParse != nil {
+			return errParse
+		}
+	}
+
+	creation, err := h.service.BeginWebAuthnRegistration(userID, input.Name)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully started WebAuthn registration", http.StatusOK, creation))
+}
+
+// FinishWebAuthnRegistration takes the PublicKeyCredential returned by
+// navigator.credentials.create, serialized as JSON, as the request body.
+func (h *handler) FinishWebAuthnRegistration(c *fiber.Ctx) error {
+
+	userID, errUser := currentUserID(c)
+	if errUser != nil {
+		return errUser
+	}
+
+	credential, err := h.service.FinishWebAuthnRegistration(userID, c.Body())
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response.BuildSuccessResponseMessage("successfully registered WebAuthn credential", http.StatusCreated, credential))
+}
+
+func (h *handler) ListWebAuthnCredentials(c *fiber.Ctx) error {
+
+	userID, errUser := currentUserID(c)
+	if errUser != nil {
+		return errUser
+	}
+
+	credentials, err := h.service.ListWebAuthnCredentials(userID)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find WebAuthn credentials", http.StatusOK, credentials))
+}
+
+func (h *handler) DeleteWebAuthnCredential(c *fiber.Ctx) error {
+
+	userID, errUser := currentUserID(c)
+	if errUser != nil {
+		return errUser
+	}
+
+	id, errID := strconv.ParseUint(c.Params("id"), 10, 32)
+	if errID != nil {
+		return &response.FailedResponseMessage{
+			Message: "Invalid Convert ID",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  errID.Error(),
+		}
+	}
+
+	if err := h.service.DeleteWebAuthnCredential(userID, uint(id)); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully deleted WebAuthn credential", http.StatusOK, nil))
+}
+
+func (h *handler) BeginWebAuthnLogin(c *fiber.Ctx) error {
+
+	var input WebAuthnLoginInput
+	if len(c.Body()) != 0 {
+		if errParse := parseAndValidate(c, &input); errParse != nil {
+			return errParse
+		}
+	}
+
+	assertion, err := h.service.BeginWebAuthnLogin(input.Username, input.Audience)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully started WebAuthn login", http.StatusOK, assertion))
+}
+
+// FinishWebAuthnLogin takes the PublicKeyCredential returned by
+// navigator.credentials.get, serialized as JSON, as the request body.
+func (h *handler) FinishWebAuthnLogin(c *fiber.Ctx) error {
+
+	token, err := h.service.FinishWebAuthnLogin(c.Body(), c.IP(), c.Get(fiber.HeaderUserAgent))
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("login successfully", http.StatusOK, token))
+}
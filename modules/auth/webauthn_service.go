@@ -0,0 +1,455 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go-jwt/common/jwt"
+	"go-jwt/common/response"
+	"go-jwt/modules/user"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultWebAuthnChallengeTTL = 5 * time.Minute
+
+	webAuthnCeremonyRegistration = "registration"
+	webAuthnCeremonyLogin        = "login"
+)
+
+// BeginWebAuthnRegistration returns the options for navigator.credentials.create
+// to register a new passkey named name. The credential has to be discoverable,
+// since logins do not list the credentials of the user.
+func (s *service) BeginWebAuthnRegistration(userID uint, name string) (*protocol.CredentialCreation, response.FailedResponseMessage) {
+
+	relyingParty, errConfig := webAuthnFromEnv()
+	if !reflect.DeepEqual(errConfig, response.FailedResponseMessage{}) {
+		return nil, errConfig
+	}
+
+	account, errAccount := s.webAuthnUser(userID)
+	if !reflect.DeepEqual(errAccount, response.FailedResponseMessage{}) {
+		return nil, errAccount
+	}
+
+	exclusions := []protocol.CredentialDescriptor{}
+	for _, credential := range account.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := relyingParty.BeginRegistration(account,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return nil, mfaFailedResponse(err, "Failed to start WebAuthn registration")
+	}
+
+	if errSave := s.saveWebAuthnChallenge(webAuthnCeremonyRegistration, session, WebAuthnChallenge{UserID: userID, Name: name}); !reflect.DeepEqual(errSave, response.FailedResponseMessage{}) {
+		return nil, errSave
+	}
+	return creation, response.FailedResponseMessage{}
+}
+
+// FinishWebAuthnRegistration checks the attestation response to a registration
+// challenge of the user and stores the new credential.
+func (s *service) FinishWebAuthnRegistration(userID uint, body []byte) (WebAuthnCredential, response.FailedResponseMessage) {
+
+	relyingParty, errConfig := webAuthnFromEnv()
+	if !reflect.DeepEqual(errConfig, response.FailedResponseMessage{}) {
+		return WebAuthnCredential{}, errConfig
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(body))
+	if err != nil {
+		return WebAuthnCredential{}, invalidWebAuthnResponse(err)
+	}
+
+	challenge, session, errChallenge := s.useWebAuthnChallenge(webAuthnCeremonyRegistration, parsed.Response.CollectedClientData.Challenge)
+	if !reflect.DeepEqual(errChallenge, response.FailedResponseMessage{}) {
+		return WebAuthnCredential{}, errChallenge
+	}
+	if challenge.UserID != userID {
+		return WebAuthnCredential{}, invalidWebAuthnResponse(errors.New("the challenge was issued to another user"))
+	}
+
+	account, errAccount := s.webAuthnUser(userID)
+	if !reflect.DeepEqual(errAccount, response.FailedResponseMessage{}) {
+		return WebAuthnCredential{}, errAccount
+	}
+
+	created, err := relyingParty.CreateCredential(account, session, parsed)
+	if err != nil {
+		return WebAuthnCredential{}, invalidWebAuthnResponse(err)
+	}
+
+	transports := []string{}
+	for _, transport := range created.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	credential, err := s.authRepo.SaveWebAuthnCredential(WebAuthnCredential{
+		UserID:          userID,
+		Name:            challenge.Name,
+		CredentialID:    created.ID,
+		PublicKey:       created.PublicKey,
+		AttestationType: created.AttestationType,
+		AAGUID:          created.Authenticator.AAGUID,
+		Transports:      strings.Join(transports, " "),
+		SignCount:       created.Authenticator.SignCount,
+		BackupEligible:  created.Flags.BackupEligible,
+		BackupState:     created.Flags.BackupState,
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return WebAuthnCredential{}, response.FailedResponseMessage{
+				Message: "Credential already registered",
+				Status:  "failed",
+				Code:    http.StatusConflict,
+				Errors:  "this authenticator is already registered",
+			}
+		}
+		return WebAuthnCredential{}, mfaFailedResponse(err, "Failed to save WebAuthn credential")
+	}
+	return credential, response.FailedResponseMessage{}
+}
+
+func (s *service) ListWebAuthnCredentials(userID uint) ([]WebAuthnCredential, response.FailedResponseMessage) {
+	credentials, err := s.authRepo.FindWebAuthnCredentialsByUserID(userID)
+	if err != nil {
+		return nil, mfaFailedResponse(err, "Failed to find WebAuthn credentials")
+	}
+	return credentials, response.FailedResponseMessage{}
+}
+
+func (s *service) DeleteWebAuthnCredential(userID, id uint) response.FailedResponseMessage {
+
+	deleted, err := s.authRepo.DeleteWebAuthnCredential(userID, id)
+	if err != nil {
+		return mfaFailedResponse(err, "Failed to delete WebAuthn credential")
+	}
+	if !deleted {
+		return response.FailedResponseMessage{
+			Message: "Credential not found",
+			Status:  "failed",
+			Code:    http.StatusNotFound,
+			Errors:  "no WebAuthn credential with id " + strconv.FormatUint(uint64(id), 10),
+		}
+	}
+	return response.FailedResponseMessage{}
+}
+
+// BeginWebAuthnLogin returns the options for navigator.credentials.get. The
+// authenticator offers its passkeys for this relying party, whether or not a
+// username is given, so the options do not tell whether an account exists or
+// which credentials it has. A known username only restricts the login to that
+// user.
+func (s *service) BeginWebAuthnLogin(username, audience string) (*protocol.CredentialAssertion, response.FailedResponseMessage) {
+
+	relyingParty, errConfig := webAuthnFromEnv()
+	if !reflect.DeepEqual(errConfig, response.FailedResponseMessage{}) {
+		return nil, errConfig
+	}
+
+	if audience != "" && !jwt.GetPolicy().Accepts(audience) {
+		return nil, response.FailedResponseMessage{
+			Message: "Invalid audience",
+			Status:  "failed",
+			Code:    http.StatusBadRequest,
+			Errors:  "audience " + audience + " is not allowed",
+		}
+	}
+
+	challenge := WebAuthnChallenge{Audience: audience}
+
+	if username != "" {
+		found, err := s.userRepo.FindUserOneUserByUsername(username)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, mfaFailedResponse(err, "Failed to find user")
+		}
+		challenge.UserID = found.ID
+	}
+
+	assertion, session, err := relyingParty.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, mfaFailedResponse(err, "Failed to start WebAuthn login")
+	}
+
+	if errSave := s.saveWebAuthnChallenge(webAuthnCeremonyLogin, session, challenge); !reflect.DeepEqual(errSave, response.FailedResponseMessage{}) {
+		return nil, errSave
+	}
+	return assertion, response.FailedResponseMessage{}
+}
+
+// FinishWebAuthnLogin checks the assertion response to a login challenge and
+// issues tokens. User verification is required by the ceremony, so the
+// credential stands in for both the password and the second factor.
+func (s *service) FinishWebAuthnLogin(body []byte, ip, userAgent string) (TokenResponse, response.FailedResponseMessage) {
+
+	relyingParty, errConfig := webAuthnFromEnv()
+	if !reflect.DeepEqual(errConfig, response.FailedResponseMessage{}) {
+		return TokenResponse{}, errConfig
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(body))
+	if err != nil {
+		return TokenResponse{}, invalidWebAuthnResponse(err)
+	}
+
+	challenge, session, errChallenge := s.useWebAuthnChallenge(webAuthnCeremonyLogin, parsed.Response.CollectedClientData.Challenge)
+	if !reflect.DeepEqual(errChallenge, response.FailedResponseMessage{}) {
+		return TokenResponse{}, errChallenge
+	}
+
+	invalid := response.FailedResponseMessage{
+		Message: "Invalid WebAuthn assertion",
+		Status:  "failed",
+		Code:    http.StatusUnauthorized,
+		Errors:  "the credential is not registered or the assertion is not valid",
+	}
+
+	stored, err := s.authRepo.FindWebAuthnCredentialByCredentialID(parsed.RawID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return TokenResponse{}, invalid
+		}
+		return TokenResponse{}, mfaFailedResponse(err, "Failed to find WebAuthn credential")
+	}
+	if challenge.UserID != 0 && challenge.UserID != stored.UserID {
+		return TokenResponse{}, invalid
+	}
+
+	account, errAccount := s.webAuthnUser(stored.UserID)
+	if !reflect.DeepEqual(errAccount, response.FailedResponseMessage{}) {
+		if errAccount.Code == http.StatusNotFound {
+			return TokenResponse{}, invalid
+		}
+		return TokenResponse{}, errAccount
+	}
+
+	asserted, err := relyingParty.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+		if !bytes.Equal(userHandle, account.WebAuthnID()) {
+			return nil, errors.New("the user handle does not match the credential")
+		}
+		return account, nil
+	}, session, parsed)
+	if err != nil {
+		return TokenResponse{}, invalid
+	}
+
+	// A counter that does not move forward means two authenticators share
+	// the key. The stored counter is left alone so the original keeps working.
+	if asserted.Authenticator.CloneWarning {
+		return TokenResponse{}, response.FailedResponseMessage{
+			Message: "Invalid WebAuthn assertion",
+			Status:  "failed",
+			Code:    http.StatusUnauthorized,
+			Errors:  "the signature counter of the authenticator went backwards, it may have been cloned",
+		}
+	}
+	used, err := s.authRepo.UseWebAuthnCredential(stored.ID, stored.SignCount, asserted.Authenticator.SignCount, asserted.Flags.BackupState)
+	if err != nil {
+		return TokenResponse{}, mfaFailedResponse(err, "Failed to update WebAuthn credential")
+	}
+	if !used {
+		return TokenResponse{}, invalid
+	}
+
+	if errVerified := checkEmailVerified(account.User); !reflect.DeepEqual(errVerified, response.FailedResponseMessage{}) {
+		return TokenResponse{}, errVerified
+	}
+
+	return s.issueTokens(account.User, challenge.Audience, ip, userAgent)
+}
+
+// webAuthnAccount adapts a user and their stored credentials to webauthn.User.
+// The user handle is the decimal user id, like the sub claim.
+type webAuthnAccount struct {
+	user.User
+	credentials []WebAuthnCredential
+}
+
+func (a *webAuthnAccount) WebAuthnID() []byte {
+	return []byte(strconv.FormatUint(uint64(a.ID), 10))
+}
+
+func (a *webAuthnAccount) WebAuthnName() string {
+	return a.Username
+}
+
+func (a *webAuthnAccount) WebAuthnDisplayName() string {
+	return a.Username
+}
+
+func (a *webAuthnAccount) WebAuthnIcon() string {
+	return ""
+}
+
+func (a *webAuthnAccount) WebAuthnCredentials() []webauthn.Credential {
+
+	credentials := make([]webauthn.Credential, 0, len(a.credentials))
+	for _, stored := range a.credentials {
+		var transports []protocol.AuthenticatorTransport
+		for _, transport := range strings.Fields(stored.Transports) {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              stored.CredentialID,
+			PublicKey:       stored.PublicKey,
+			AttestationType: stored.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: stored.BackupEligible,
+				BackupState:    stored.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    stored.AAGUID,
+				SignCount: stored.SignCount,
+			},
+		})
+	}
+	return credentials
+}
+
+func (s *service) webAuthnUser(userID uint) (*webAuthnAccount, response.FailedResponseMessage) {
+
+	found, err := s.userRepo.FindOneUserByID(userID)
+	if err != nil {
+		return nil, mfaFailedResponse(err, "Failed to find user")
+	}
+
+	credentials, err := s.authRepo.FindWebAuthnCredentialsByUserID(userID)
+	if err != nil {
+		return nil, mfaFailedResponse(err, "Failed to find WebAuthn credentials")
+	}
+	return &webAuthnAccount{User: found, credentials: credentials}, response.FailedResponseMessage{}
+}
+
+func (s *service) saveWebAuthnChallenge(ceremony string, session *webauthn.SessionData, challenge WebAuthnChallenge) response.FailedResponseMessage {
+
+	encoded, err := json.Marshal(session)
+	if err != nil {
+		return mfaFailedResponse(err, "Failed to encode WebAuthn session")
+	}
+
+	challenge.Challenge = session.Challenge
+	challenge.Ceremony = ceremony
+	challenge.Session = string(encoded)
+	challenge.ExpiresAt = time.Now().Add(durationFromEnv("WEBAUTHN_CHALLENGE_TTL", defaultWebAuthnChallengeTTL))
+
+	if _, err := s.authRepo.SaveWebAuthnChallenge(challenge); err != nil {
+		return mfaFailedResponse(err, "Failed to save WebAuthn challenge")
+	}
+	return response.FailedResponseMessage{}
+}
+
+// useWebAuthnChallenge finds the unexpired challenge of the ceremony that the
+// client data echoes and marks it as used.
+func (s *service) useWebAuthnChallenge(ceremony, value string) (WebAuthnChallenge, webauthn.SessionData, response.FailedResponseMessage) {
+
+	invalid := response.FailedResponseMessage{
+		Message: "Invalid WebAuthn challenge",
+		Status:  "failed",
+		Code:    http.StatusBadRequest,
+		Errors:  "the challenge is unknown, expired or already used, please start again",
+	}
+
+	challenge, err := s.authRepo.FindWebAuthnChallenge(value)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return WebAuthnChallenge{}, webauthn.SessionData{}, invalid
+		}
+		return WebAuthnChallenge{}, webauthn.SessionData{}, mfaFailedResponse(err, "Failed to find WebAuthn challenge")
+	}
+	if challenge.Ceremony != ceremony || challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return WebAuthnChallenge{}, webauthn.SessionData{}, invalid
+	}
+
+	used, err := s.authRepo.UseWebAuthnChallenge(challenge.ID)
+	if err != nil {
+		return WebAuthnChallenge{}, webauthn.SessionData{}, mfaFailedResponse(err, "Failed to use WebAuthn challenge")
+	}
+	if !used {
+		return WebAuthnChallenge{}, webauthn.SessionData{}, invalid
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(challenge.Session), &session); err != nil {
+		return WebAuthnChallenge{}, webauthn.SessionData{}, mfaFailedResponse(err, "Failed to decode WebAuthn session")
+	}
+	return challenge, session, response.FailedResponseMessage{}
+}
+
+// webAuthnFromEnv reads the relying party. WebAuthn is disabled until
+// WEBAUTHN_RP_ID, the domain the credentials are bound to, is set.
+//
+//	WEBAUTHN_RP_ID          registrable domain of the login page, e.g. example.com
+//	WEBAUTHN_RP_NAME        name shown by the authenticator, default the token issuer
+//	WEBAUTHN_RP_ORIGINS     comma separated origins of the login page, default https://<rp id>
+//	WEBAUTHN_CHALLENGE_TTL  how long a ceremony may take, default 5m
+func webAuthnFromEnv() (*webauthn.WebAuthn, response.FailedResponseMessage) {
+
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		return nil, response.FailedResponseMessage{
+			Message: "WebAuthn is disabled",
+			Status:  "failed",
+			Code:    http.StatusForbidden,
+			Errors:  "log in with a username and password instead",
+		}
+	}
+
+	name := os.Getenv("WEBAUTHN_RP_NAME")
+	if name == "" {
+		name = jwt.GetPolicy().Issuer
+	}
+
+	origins := []string{}
+	for _, origin := range strings.Split(os.Getenv("WEBAUTHN_RP_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		origins = []string{"https://" + rpID}
+	}
+
+	relyingParty, err := webauthn.New(&webauthn.Config{
+		RPID:                  rpID,
+		RPDisplayName:         name,
+		RPOrigins:             origins,
+		AttestationPreference: protocol.PreferNoAttestation,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			UserVerification: protocol.VerificationRequired,
+		},
+	})
+	if err != nil {
+		return nil, mfaFailedResponse(err, "Invalid WebAuthn configuration")
+	}
+	return relyingParty, response.FailedResponseMessage{}
+}
+
+func invalidWebAuthnResponse(err error) response.FailedResponseMessage {
+
+	detail := err.Error()
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) && protocolErr.DevInfo != "" {
+		detail += ": " + protocolErr.DevInfo
+	}
+
+	return response.FailedResponseMessage{
+		Message: "Invalid WebAuthn response",
+		Status:  "failed",
+		Code:    http.StatusBadRequest,
+		Errors:  detail,
+	}
+}
@@ -0,0 +1,57 @@
+package authz
+
+import (
+	"go-jwt/common/policy"
+	"go-jwt/common/response"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type handler struct{}
+
+func NewHandler() *handler {
+	return &handler{}
+}
+
+// Check evaluates the policy for another service and returns the decision.
+func (h *handler) Check(c *fiber.Ctx) error {
+
+	var input CheckInput
+	if err := c.BodyParser(&input); err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Failed to parse request body",
+			Status:  "failed",
+			Code:    fiber.StatusUnprocessableEntity,
+			Errors:  err.Error(),
+		}
+	}
+
+	validation := response.ValidateBodyRequest(input)
+	if len(validation) != 0 {
+		return &response.FailedResponseMessage{
+			Message: "Failed request body",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  validation,
+		}
+	}
+
+	if input.Subject == nil {
+		input.Subject = policy.GetSubject(c)
+	}
+
+	context := policy.RequestContext(c)
+	for name, value := range input.Context {
+		context[name] = value
+	}
+
+	decision := policy.GetEngine().Evaluate(policy.Request{
+		Subject:  input.Subject,
+		Action:   input.Action,
+		Resource: input.Resource,
+		Context:  context,
+	})
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully evaluated policy", http.StatusOK, decision))
+}
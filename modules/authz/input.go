@@ -0,0 +1,12 @@
+package authz
+
+import "go-jwt/common/policy"
+
+type CheckInput struct {
+	// Subject defaults to the caller when omitted.
+	Subject  policy.Attributes `json:"subject"`
+	Action   string            `json:"action" validate:"required"`
+	Resource policy.Resource   `json:"resource"`
+	// Context is merged over the attributes of the check request itself.
+	Context policy.Attributes `json:"context"`
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"go-jwt/common/response"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *handler {
+	return &handler{service: service}
+}
+
+func (h *handler) Create(c *fiber.Ctx) error {
+
+	var input RegisterInputClient
+	if err := parseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	credentials, err := h.service.Save(input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully created client", http.StatusOK, credentials))
+}
+
+func (h *handler) FindClients(c *fiber.Ctx) error {
+
+	var criteria Client
+	if err := c.BodyParser(&criteria); err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Failed to parse request body",
+			Status:  "failed",
+			Code:    fiber.StatusUnprocessableEntity,
+			Errors:  err.Error(),
+		}
+	}
+
+	clients, err := h.service.FindClientsByCriteria(criteria)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find client", http.StatusOK, clients))
+}
+
+func (h *handler) FindOneClientByID(c *fiber.Ctx) error {
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	client, err := h.service.FindOneClientByID(id)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find client", http.StatusOK, client))
+}
+
+func (h *handler) Update(c *fiber.Ctx) error {
+
+	var input UpdateInputClient
+	if err := parseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	client, err := h.service.UpdateOne(id, input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully updated client", http.StatusOK, client))
+}
+
+func (h *handler) SoftDelete(c *fiber.Ctx) error {
+
+	var input SoftDeleteInputClient
+	if err := parseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	if err := h.service.SoftDelete(id, input); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully soft deleted client", http.StatusOK, nil))
+}
+
+func (h *handler) RotateSecret(c *fiber.Ctx) error {
+
+	var input RotateSecretInputClient
+	if err := parseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	credentials, err := h.service.RotateSecret(id, input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully rotated client secret", http.StatusOK, credentials))
+}
+
+func parseAndValidate(c *fiber.Ctx, input interface{}) error {
+
+	if err := c.BodyParser(input); err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Failed to parse request body",
+			Status:  "failed",
+			Code:    fiber.StatusUnprocessableEntity,
+			Errors:  err.Error(),
+		}
+	}
+
+	validation := response.ValidateBodyRequest(input)
+	if len(validation) != 0 {
+		return &response.FailedResponseMessage{
+			Message: "Failed request body",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  validation,
+		}
+	}
+
+	return nil
+}
+
+func parseID(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return 0, &response.FailedResponseMessage{
+			Message: "Invalid Convert ID",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  err.Error(),
+		}
+	}
+	return uint(id), nil
+}
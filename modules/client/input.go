@@ -0,0 +1,27 @@
+package client
+
+type (
+	RegisterInputClient struct {
+		Name         string   `json:"name" validate:"required"`
+		Public       bool     `json:"public"`
+		Scope        []string `json:"scope"`
+		Audience     []string `json:"audience"`
+		RedirectURIs []string `json:"redirect_uris" validate:"dive,url"`
+	}
+
+	UpdateInputClient struct {
+		Name         string   `json:"name" validate:"required"`
+		Scope        []string `json:"scope"`
+		Audience     []string `json:"audience"`
+		RedirectURIs []string `json:"redirect_uris" validate:"dive,url"`
+		Version      int64    `json:"version" validate:"required"`
+	}
+
+	SoftDeleteInputClient struct {
+		Version int64 `json:"version" validate:"required"`
+	}
+
+	RotateSecretInputClient struct {
+		Version int64 `json:"version" validate:"required"`
+	}
+)
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Client is an OAuth client. Scope, Audience and RedirectURIs are space
+// separated lists of what the client may request. Only a bcrypt hash of the
+// secret is stored. Public clients such as SPAs and mobile apps cannot keep a
+// secret; they may only use the authorization code flow with PKCE.
+type Client struct {
+	ID           uint           `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	ClientID     string         `gorm:"not null;unique" json:"client_id"`
+	Name         string         `gorm:"not null" json:"name"`
+	SecretHash   string         `gorm:"not null" json:"-"`
+	Public       bool           `gorm:"not null;default:false" json:"public"`
+	Scope        string         `json:"scope"`
+	Audience     string         `json:"audience"`
+	RedirectURIs string         `json:"redirect_uris"`
+	Version      int64          `gorm:"not null" json:"version"`
+}
+
+// Credentials is returned when a client is created or its secret rotated. It
+// is the only time the plain secret is available.
+type Credentials struct {
+	Client
+	ClientSecret string `json:"client_secret"`
+}
+
+func (c Client) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+func (c Client) Audiences() []string {
+	return strings.Fields(c.Audience)
+}
+
+// AllowsRedirectURI reports whether uri is registered for the client. Only
+// exact matches are accepted.
+func (c Client) AllowsRedirectURI(uri string) bool {
+	for _, registered := range strings.Fields(c.RedirectURIs) {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifySecret reports whether secret matches the stored hash.
+func (c Client) VerifySecret(secret string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(c.SecretHash), []byte(secret)) == nil
+}
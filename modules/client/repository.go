@@ -0,0 +1,137 @@
+package client
+
+import (
+	"go-jwt/common/response"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository interface {
+	Save(client Client) (Client, error)
+	FindOneClientByID(id uint) (Client, error)
+	FindOneClientByClientID(clientID string) (Client, error)
+	FindClientsByCriteria(client Client) ([]Client, error)
+	FindOneAndLockAndUpdate(id uint, input UpdateInputClient) (Client, error)
+	SoftDelete(id uint, input SoftDeleteInputClient) error
+	RotateSecret(id uint, input RotateSecretInputClient, secretHash string) (Client, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Save(client Client) (Client, error) {
+	if err := r.db.Save(&client).Error; err != nil {
+		return Client{}, err
+	}
+	return client, nil
+}
+
+func (r *repository) FindOneClientByID(id uint) (Client, error) {
+	var client Client
+	if err := r.db.First(&client, id).Error; err != nil {
+		return Client{}, err
+	}
+	return client, nil
+}
+
+func (r *repository) FindOneClientByClientID(clientID string) (Client, error) {
+	var client Client
+	if err := r.db.Where(&Client{ClientID: clientID}).First(&client).Error; err != nil {
+		return Client{}, err
+	}
+	return client, nil
+}
+
+func (r *repository) FindClientsByCriteria(client Client) ([]Client, error) {
+	var result []Client
+	if err := r.db.Where(&client).Find(&result).Error; err != nil {
+		return []Client{}, err
+	}
+	return result, nil
+}
+
+func (r *repository) FindOneAndLockAndUpdate(id uint, input UpdateInputClient) (Client, error) {
+
+	var client Client
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := lockAndCheckVersion(tx, &client, id, input.Version); err != nil {
+			return err
+		}
+
+		return tx.Model(&client).Updates(map[string]interface{}{
+			"name":          input.Name,
+			"scope":         strings.Join(input.Scope, " "),
+			"audience":      strings.Join(input.Audience, " "),
+			"redirect_uris": strings.Join(input.RedirectURIs, " "),
+			"version":       time.Now().UnixMilli(),
+		}).Error
+	})
+
+	if err != nil {
+		return Client{}, err
+	}
+
+	return client, nil
+}
+
+func (r *repository) SoftDelete(id uint, input SoftDeleteInputClient) error {
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+
+		var client Client
+		if err := lockAndCheckVersion(tx, &client, id, input.Version); err != nil {
+			return err
+		}
+
+		return tx.Delete(&Client{ID: id}).Error
+	})
+}
+
+func (r *repository) RotateSecret(id uint, input RotateSecretInputClient, secretHash string) (Client, error) {
+
+	var client Client
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := lockAndCheckVersion(tx, &client, id, input.Version); err != nil {
+			return err
+		}
+
+		return tx.Model(&client).Updates(Client{SecretHash: secretHash, Version: time.Now().UnixMilli()}).Error
+	})
+
+	if err != nil {
+		return Client{}, err
+	}
+
+	return client, nil
+}
+
+func lockAndCheckVersion(tx *gorm.DB, client *Client, id uint, version int64) error {
+
+	if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(client, id).Error; err != nil {
+		return err
+	}
+
+	if client.Version != version {
+		return &response.FailedResponseMessage{
+			Message: "Version mismatch",
+			Code:    fiber.StatusConflict,
+			Status:  "failed",
+			Errors:  "The version of the resource you're trying to update has changed. Please make sure to get the latest version before trying again.",
+		}
+	}
+
+	return nil
+}
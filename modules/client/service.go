@@ -0,0 +1,156 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"go-jwt/common/response"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type Service interface {
+	Save(input RegisterInputClient) (Credentials, response.FailedResponseMessage)
+	FindOneClientByID(id uint) (Client, response.FailedResponseMessage)
+	FindClientsByCriteria(client Client) ([]Client, response.FailedResponseMessage)
+	UpdateOne(id uint, input UpdateInputClient) (Client, response.FailedResponseMessage)
+	SoftDelete(id uint, input SoftDeleteInputClient) response.FailedResponseMessage
+	RotateSecret(id uint, input RotateSecretInputClient) (Credentials, response.FailedResponseMessage)
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) Save(input RegisterInputClient) (Credentials, response.FailedResponseMessage) {
+
+	secret, secretHash, err := newSecret()
+	if err != nil {
+		return Credentials{}, response.FailedResponseMessage{
+			Message: "Failed to generate client secret",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	client, err := s.repo.Save(Client{
+		ClientID:     uuid.NewString(),
+		Name:         input.Name,
+		SecretHash:   secretHash,
+		Public:       input.Public,
+		Scope:        strings.Join(input.Scope, " "),
+		Audience:     strings.Join(input.Audience, " "),
+		RedirectURIs: strings.Join(input.RedirectURIs, " "),
+		Version:      time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return Credentials{}, response.FailedResponseMessage{
+			Message: "Failed to save client",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	return Credentials{Client: client, ClientSecret: secret}, response.FailedResponseMessage{}
+}
+
+func (s *service) FindOneClientByID(id uint) (Client, response.FailedResponseMessage) {
+	client, err := s.repo.FindOneClientByID(id)
+	if err != nil {
+		return Client{}, clientFailedResponse(err, "Failed to get client")
+	}
+	return client, response.FailedResponseMessage{}
+}
+
+func (s *service) FindClientsByCriteria(client Client) ([]Client, response.FailedResponseMessage) {
+	clients, err := s.repo.FindClientsByCriteria(client)
+	if err != nil {
+		return []Client{}, response.FailedResponseMessage{
+			Message: "failed to find client",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	return clients, response.FailedResponseMessage{}
+}
+
+func (s *service) UpdateOne(id uint, input UpdateInputClient) (Client, response.FailedResponseMessage) {
+	client, err := s.repo.FindOneAndLockAndUpdate(id, input)
+	if err != nil {
+		return Client{}, clientFailedResponse(err, "Failed to update client")
+	}
+	return client, response.FailedResponseMessage{}
+}
+
+func (s *service) SoftDelete(id uint, input SoftDeleteInputClient) response.FailedResponseMessage {
+	if err := s.repo.SoftDelete(id, input); err != nil {
+		return clientFailedResponse(err, "Failed to soft delete client")
+	}
+	return response.FailedResponseMessage{}
+}
+
+func (s *service) RotateSecret(id uint, input RotateSecretInputClient) (Credentials, response.FailedResponseMessage) {
+
+	secret, secretHash, err := newSecret()
+	if err != nil {
+		return Credentials{}, response.FailedResponseMessage{
+			Message: "Failed to generate client secret",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	client, err := s.repo.RotateSecret(id, input, secretHash)
+	if err != nil {
+		return Credentials{}, clientFailedResponse(err, "Failed to rotate client secret")
+	}
+
+	return Credentials{Client: client, ClientSecret: secret}, response.FailedResponseMessage{}
+}
+
+// newSecret returns a random client secret and its bcrypt hash.
+func newSecret() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, string(hash), nil
+}
+
+func clientFailedResponse(err error, message string) response.FailedResponseMessage {
+	var responseErr *response.FailedResponseMessage
+	if errors.As(err, &responseErr) {
+		return *responseErr
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.FailedResponseMessage{
+			Message: "Client not found",
+			Status:  "failed",
+			Code:    http.StatusNotFound,
+			Errors:  err.Error(),
+		}
+	}
+	return response.FailedResponseMessage{
+		Message: message,
+		Status:  "failed",
+		Code:    http.StatusInternalServerError,
+		Errors:  err.Error(),
+	}
+}
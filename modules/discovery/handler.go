@@ -0,0 +1,81 @@
+package discovery
+
+import (
+	"go-jwt/common/jwt"
+	"go-jwt/modules/auth"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// jwksMaxAge is kept short so verifiers pick up a rotated key well within the
+// grace period of the key it replaces.
+const jwksMaxAge = "public, max-age=300, must-revalidate"
+
+type handler struct{}
+
+func NewHandler() *handler {
+	return &handler{}
+}
+
+type openIDConfiguration struct {
+	Issuer                           string   `json:"issuer"`
+	JwksURI                          string   `json:"jwks_uri"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint"`
+	ScopesSupported                  []string `json:"scopes_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	GrantTypesSupported              []string `json:"grant_types_supported"`
+	TokenEndpointAuthMethods         []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported    []string `json:"code_challenge_methods_supported"`
+}
+
+func (h *handler) JWKS(c *fiber.Ctx) error {
+
+	keyring := jwt.GetKeyring()
+	etag := keyring.ETag()
+
+	c.Set(fiber.HeaderCacheControl, jwksMaxAge)
+	c.Set(fiber.HeaderETag, etag)
+	if c.Get(fiber.HeaderIfNoneMatch) == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(keyring.JWKS())
+}
+
+func (h *handler) OpenIDConfiguration(c *fiber.Ctx) error {
+
+	baseURL := publicBaseURL(c)
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=3600")
+	return c.Status(fiber.StatusOK).JSON(openIDConfiguration{
+		Issuer:                           jwt.GetPolicy().Issuer,
+		JwksURI:                          baseURL + "/.well-known/jwks.json",
+		AuthorizationEndpoint:            baseURL + "/api/auth/authorize",
+		TokenEndpoint:                    baseURL + "/api/auth/token",
+		UserinfoEndpoint:                 baseURL + "/api/auth/userinfo",
+		ScopesSupported:                  []string{auth.ScopeOpenID, auth.ScopeProfile},
+		ClaimsSupported:                  []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "azp", "preferred_username", "updated_at"},
+		ResponseTypesSupported:           []string{"code"},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: jwt.GetKeyring().Algorithms(),
+		GrantTypesSupported:              []string{"authorization_code", "client_credentials", "refresh_token"},
+		TokenEndpointAuthMethods:         []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:    []string{"S256"},
+	})
+}
+
+// publicBaseURL prefers PUBLIC_BASE_URL so the advertised endpoints are right
+// behind a reverse proxy.
+func publicBaseURL(c *fiber.Ctx) string {
+	if baseURL := os.Getenv("PUBLIC_BASE_URL"); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	return c.BaseURL()
+}
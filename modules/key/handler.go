@@ -0,0 +1,55 @@
+package key
+
+import (
+	"go-jwt/common/jwt"
+	"go-jwt/common/response"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type handler struct{}
+
+func NewHandler() *handler {
+	return &handler{}
+}
+
+type keyResponse struct {
+	ID        string     `json:"kid"`
+	Algorithm string     `json:"alg"`
+	Active    bool       `json:"active"`
+	RetiredAt *time.Time `json:"retired_at"`
+}
+
+func (h *handler) FindKeys(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find keys", http.StatusOK, describeKeys()))
+}
+
+// Rotate reloads the key configuration and activates the configured key if it changed.
+func (h *handler) Rotate(c *fiber.Ctx) error {
+
+	if err := jwt.ReloadKeys(); err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Failed to rotate signing key",
+			Status:  "failed",
+			Code:    fiber.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully rotated keys", http.StatusOK, describeKeys()))
+}
+
+func describeKeys() []keyResponse {
+	var keys []keyResponse
+	for i, key := range jwt.GetKeyring().Keys() {
+		item := keyResponse{ID: key.ID, Algorithm: key.Method.Alg(), Active: i == 0}
+		if !key.RetiredAt.IsZero() {
+			retiredAt := key.RetiredAt
+			item.RetiredAt = &retiredAt
+		}
+		keys = append(keys, item)
+	}
+	return keys
+}
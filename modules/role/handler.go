@@ -1,6 +1,7 @@
 package role
 
 import (
+	"go-jwt/common/policy"
 	"go-jwt/common/response"
 	"net/http"
 	"reflect"
@@ -10,11 +11,12 @@ import (
 )
 
 type handler struct {
-	service Service
+	service           Service
+	permissionService PermissionService
 }
 
-func NewHandler(service Service) *handler {
-	return &handler{service}
+func NewHandler(service Service, permissionService PermissionService) *handler {
+	return &handler{service, permissionService}
 }
 
 func (h *handler) Create(c *fiber.Ctx) error {
@@ -29,6 +31,11 @@ func (h *handler) Create(c *fiber.Ctx) error {
 			Errors:  err.Error(),
 		}
 	}
+	resource := policy.Resource{Type: "role", Attributes: policy.Attributes{"name": input.Name, "parent_id": parentID(input.ParentID)}}
+	if err := policy.Authorize(c, PermissionRoleCreate, resource); err != nil {
+		return err
+	}
+
 	user, err := h.service.Save(input)
 	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
 		return &err
@@ -101,6 +108,10 @@ func (h *handler) Update(c *fiber.Ctx) error {
 	}
 	uintID := uint(id)
 
+	if err := h.authorize(c, PermissionRoleUpdate, uintID, policy.Attributes{"new_name": input.Name, "new_parent_id": parentID(input.ParentID)}); err != nil {
+		return err
+	}
+
 	update, errUpdate := h.service.UpdateOne(uintID, input)
 	if !reflect.DeepEqual(errUpdate, response.FailedResponseMessage{}) {
 		return &errUpdate
@@ -165,6 +176,10 @@ func (h *handler) SoftDelete(c *fiber.Ctx) error {
 	}
 	uintID := uint(id)
 
+	if err := h.authorize(c, PermissionRoleDelete, uintID, nil); err != nil {
+		return err
+	}
+
 	errUpdate := h.service.SoftDelete(uintID, input)
 	if !reflect.DeepEqual(errUpdate, response.FailedResponseMessage{}) {
 		return &errUpdate
@@ -177,6 +192,10 @@ func (h *handler) RestoreSoftDelete(c *fiber.Ctx) error {
 
 	name := c.Params("name")
 
+	if err := policy.Authorize(c, PermissionRoleUpdate, policy.Resource{Type: "role", Attributes: policy.Attributes{"name": name}}); err != nil {
+		return err
+	}
+
 	role, err := h.service.RestoreDataSoftDelete(name)
 	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
 		return &err
@@ -184,3 +203,27 @@ func (h *handler) RestoreSoftDelete(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully restored role", http.StatusOK, role))
 }
+
+// authorize asks the policy engine whether the caller may perform action on
+// the role. extra is merged into the role's attributes.
+func (h *handler) authorize(c *fiber.Ctx, action string, id uint, extra policy.Attributes) error {
+
+	role, err := h.service.FindOneRoleByID(id)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	attributes := policy.Attributes{"id": role.ID, "name": role.Name, "parent_id": parentID(role.ParentID)}
+	for name, value := range extra {
+		attributes[name] = value
+	}
+
+	return policy.Authorize(c, action, policy.Resource{Type: "role", Attributes: attributes})
+}
+
+func parentID(id *uint) interface{} {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
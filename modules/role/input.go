@@ -2,15 +2,38 @@ package role
 
 type (
 	RegisterInputRole struct {
-		Name string `json:"name" validate:"required"`
+		Name     string `json:"name" validate:"required"`
+		ParentID *uint  `json:"parent_id"`
 	}
 
+	// UpdateInputRole leaves the parent untouched when ParentID is omitted
+	// and removes it when ParentID is 0.
 	UpdateInputRole struct {
-		Name    string `json:"name" validate:"required"`
-		Version int64  `json:"version" validate:"required"`
+		Name     string `json:"name" validate:"required"`
+		ParentID *uint  `json:"parent_id"`
+		Version  int64  `json:"version" validate:"required"`
 	}
 
 	SoftDeleteInputRole struct {
 		Version int64 `json:"version" validate:"required"`
 	}
+
+	RegisterInputPermission struct {
+		Name        string `json:"name" validate:"required"`
+		Description string `json:"description"`
+	}
+
+	UpdateInputPermission struct {
+		Name        string `json:"name" validate:"required"`
+		Description string `json:"description"`
+		Version     int64  `json:"version" validate:"required"`
+	}
+
+	SoftDeleteInputPermission struct {
+		Version int64 `json:"version" validate:"required"`
+	}
+
+	GrantInputPermission struct {
+		Permissions []string `json:"permissions" validate:"required,min=1,dive,required"`
+	}
 )
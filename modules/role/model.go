@@ -6,11 +6,28 @@ import (
 	"gorm.io/gorm"
 )
 
+// Role groups permissions. A role inherits every permission of its parent,
+// so with staff as the parent of manager and manager as the parent of admin,
+// admin holds the permissions of all three.
 type Role struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
-	Name      string         `gorm:"not null;unique" json:"name"`
-	Version   int64          `gorm:"not null" json:"version"`
+	ID          uint           `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	Name        string         `gorm:"not null;unique" json:"name"`
+	ParentID    *uint          `gorm:"index" json:"parent_id"`
+	Version     int64          `gorm:"not null" json:"version"`
+	Permissions []Permission   `gorm:"many2many:role_permissions" json:"permissions,omitempty"`
+}
+
+// Permission is a named action such as "role:create" or "user:delete" that
+// can be granted to roles.
+type Permission struct {
+	ID          uint           `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	Name        string         `gorm:"not null;unique" json:"name"`
+	Description string         `json:"description"`
+	Version     int64          `gorm:"not null" json:"version"`
 }
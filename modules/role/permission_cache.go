@@ -0,0 +1,84 @@
+package role
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultPermissionCacheTTL = time.Minute
+
+type permissionCacheEntry struct {
+	permissions map[string]bool
+	expires     time.Time
+}
+
+// PermissionCache caches the effective permission names of each role. Entries
+// expire after PERMISSION_CACHE_TTL and are dropped whenever this instance
+// changes a grant.
+type PermissionCache struct {
+	repo    PermissionRepository
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[uint]permissionCacheEntry
+}
+
+func NewPermissionCache(repo PermissionRepository) *PermissionCache {
+	ttl, err := time.ParseDuration(os.Getenv("PERMISSION_CACHE_TTL"))
+	if err != nil || ttl < 0 {
+		ttl = defaultPermissionCacheTTL
+	}
+	return &PermissionCache{repo: repo, ttl: ttl, entries: map[uint]permissionCacheEntry{}}
+}
+
+// HasPermission reports whether any of the roles has been granted permission,
+// either directly or through a role it inherits from.
+func (c *PermissionCache) HasPermission(roleIDs []uint, permission string) (bool, error) {
+	for _, roleID := range roleIDs {
+		permissions, err := c.permissions(roleID)
+		if err != nil {
+			return false, err
+		}
+		if permissions[permission] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *PermissionCache) permissions(roleID uint) (map[string]bool, error) {
+
+	now := time.Now()
+
+	c.mu.RLock()
+	entry, ok := c.entries[roleID]
+	c.mu.RUnlock()
+
+	if !ok || now.After(entry.expires) {
+		permissions, err := c.repo.FindEffectivePermissionsByRoleID(roleID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		entry = permissionCacheEntry{permissions: map[string]bool{}, expires: now.Add(c.ttl)}
+		for _, granted := range permissions {
+			entry.permissions[granted.Name] = true
+		}
+
+		c.mu.Lock()
+		c.entries[roleID] = entry
+		c.mu.Unlock()
+	}
+
+	return entry.permissions, nil
+}
+
+// Invalidate drops every cached entry.
+func (c *PermissionCache) Invalidate() {
+	c.mu.Lock()
+	c.entries = map[uint]permissionCacheEntry{}
+	c.mu.Unlock()
+}
@@ -0,0 +1,215 @@
+package role
+
+import (
+	"go-jwt/common/policy"
+	"go-jwt/common/response"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *handler) CreatePermission(c *fiber.Ctx) error {
+
+	var input RegisterInputPermission
+	if err := parseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	permission, err := h.permissionService.Save(input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully created permission", http.StatusOK, permission))
+}
+
+func (h *handler) FindPermissions(c *fiber.Ctx) error {
+
+	var criteria Permission
+	if err := c.BodyParser(&criteria); err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Failed to parse request body",
+			Status:  "failed",
+			Code:    fiber.StatusUnprocessableEntity,
+			Errors:  err.Error(),
+		}
+	}
+
+	permissions, err := h.permissionService.FindPermissionsByCriteria(criteria)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find permission", http.StatusOK, permissions))
+}
+
+func (h *handler) FindOnePermissionByID(c *fiber.Ctx) error {
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	permission, err := h.permissionService.FindOnePermissionByID(id)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find permission", http.StatusOK, permission))
+}
+
+func (h *handler) UpdatePermission(c *fiber.Ctx) error {
+
+	var input UpdateInputPermission
+	if err := parseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	permission, err := h.permissionService.UpdateOne(id, input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully updated permission", http.StatusOK, permission))
+}
+
+func (h *handler) SoftDeletePermission(c *fiber.Ctx) error {
+
+	var input SoftDeleteInputPermission
+	if err := parseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	if err := h.permissionService.SoftDelete(id, input); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully soft deleted permission", http.StatusOK, nil))
+}
+
+func (h *handler) FindRolePermissions(c *fiber.Ctx) error {
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	permissions, err := h.permissionService.FindPermissionsByRoleID(id)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find role permissions", http.StatusOK, permissions))
+}
+
+func (h *handler) FindRoleEffectivePermissions(c *fiber.Ctx) error {
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	permissions, err := h.permissionService.FindEffectivePermissionsByRoleID(id)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find effective role permissions", http.StatusOK, permissions))
+}
+
+func (h *handler) GrantPermissions(c *fiber.Ctx) error {
+
+	var input GrantInputPermission
+	if err := parseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	if err := h.authorize(c, PermissionRoleGrant, id, policy.Attributes{"permissions": input.Permissions}); err != nil {
+		return err
+	}
+
+	permissions, err := h.permissionService.GrantPermissions(id, input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully granted permissions", http.StatusOK, permissions))
+}
+
+func (h *handler) RevokePermissions(c *fiber.Ctx) error {
+
+	var input GrantInputPermission
+	if err := parseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	if err := h.authorize(c, PermissionRoleGrant, id, policy.Attributes{"permissions": input.Permissions}); err != nil {
+		return err
+	}
+
+	permissions, err := h.permissionService.RevokePermissions(id, input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully revoked permissions", http.StatusOK, permissions))
+}
+
+func parseAndValidate(c *fiber.Ctx, input interface{}) error {
+
+	if err := c.BodyParser(input); err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Failed to parse request body",
+			Status:  "failed",
+			Code:    fiber.StatusUnprocessableEntity,
+			Errors:  err.Error(),
+		}
+	}
+
+	validation := response.ValidateBodyRequest(input)
+	if len(validation) != 0 {
+		return &response.FailedResponseMessage{
+			Message: "Failed request body",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  validation,
+		}
+	}
+
+	return nil
+}
+
+func parseID(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return 0, &response.FailedResponseMessage{
+			Message: "Invalid Convert ID",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  err.Error(),
+		}
+	}
+	return uint(id), nil
+}
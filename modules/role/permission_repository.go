@@ -0,0 +1,230 @@
+package role
+
+import (
+	"go-jwt/common/response"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PermissionRepository interface {
+	Save(permission Permission) (Permission, error)
+	FindOnePermissionByID(id uint) (Permission, error)
+	FindPermissionsByCriteria(permission Permission) ([]Permission, error)
+	FindOneAndLockAndUpdate(id uint, input UpdateInputPermission) (Permission, error)
+	SoftDelete(id uint, input SoftDeleteInputPermission) error
+	FindPermissionsByRoleID(roleID uint) ([]Permission, error)
+	FindEffectivePermissionsByRoleID(roleID uint) ([]Permission, error)
+	GrantPermissions(roleID uint, names []string) ([]Permission, error)
+	RevokePermissions(roleID uint, names []string) ([]Permission, error)
+}
+
+type permissionRepository struct {
+	db *gorm.DB
+}
+
+func NewPermissionRepository(db *gorm.DB) PermissionRepository {
+	return &permissionRepository{db: db}
+}
+
+func (r *permissionRepository) Save(permission Permission) (Permission, error) {
+	if err := r.db.Save(&permission).Error; err != nil {
+		return Permission{}, err
+	}
+	return permission, nil
+}
+
+func (r *permissionRepository) FindOnePermissionByID(id uint) (Permission, error) {
+	var permission Permission
+	if err := r.db.First(&permission, id).Error; err != nil {
+		return Permission{}, err
+	}
+	return permission, nil
+}
+
+func (r *permissionRepository) FindPermissionsByCriteria(permission Permission) ([]Permission, error) {
+	var result []Permission
+	if err := r.db.Where(&permission).Find(&result).Error; err != nil {
+		return []Permission{}, err
+	}
+	return result, nil
+}
+
+func (r *permissionRepository) FindOneAndLockAndUpdate(id uint, input UpdateInputPermission) (Permission, error) {
+
+	var permission Permission
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&permission, id).Error; err != nil {
+			return err
+		}
+
+		if permission.Version != input.Version {
+			return &response.FailedResponseMessage{
+				Message: "Version mismatch",
+				Code:    fiber.StatusConflict,
+				Status:  "failed",
+				Errors:  "The version of the resource you're trying to update has changed. Please make sure to get the latest version before trying again.",
+			}
+		}
+
+		input.Version = time.Now().UnixMilli()
+
+		if err := tx.Model(&permission).Updates(input).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+
+	if err != nil {
+		return Permission{}, err
+	}
+
+	return permission, nil
+}
+
+func (r *permissionRepository) SoftDelete(id uint, input SoftDeleteInputPermission) error {
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+
+		var permission Permission
+
+		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&permission, id).Error; err != nil {
+			return err
+		}
+
+		if permission.Version != input.Version {
+			return &response.FailedResponseMessage{
+				Message: "Version mismatch",
+				Code:    fiber.StatusConflict,
+				Status:  "failed",
+				Errors:  "The version of the resource you're trying to update has changed. Please make sure to get the latest version before trying again.",
+			}
+		}
+
+		if err := tx.Exec("DELETE FROM role_permissions WHERE permission_id = ?", id).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&Permission{ID: id}).Error
+	})
+}
+
+func (r *permissionRepository) FindPermissionsByRoleID(roleID uint) ([]Permission, error) {
+
+	var role Role
+	if err := r.db.Preload("Permissions").First(&role, roleID).Error; err != nil {
+		return []Permission{}, err
+	}
+
+	return role.Permissions, nil
+}
+
+// FindEffectivePermissionsByRoleID returns the permissions granted to the role
+// and to every role it inherits from. The walk stops at a deleted parent.
+func (r *permissionRepository) FindEffectivePermissionsByRoleID(roleID uint) ([]Permission, error) {
+
+	var role Role
+	if err := r.db.Preload("Permissions").First(&role, roleID).Error; err != nil {
+		return []Permission{}, err
+	}
+
+	permissions := []Permission{}
+	seen := map[uint]bool{}
+	visited := map[uint]bool{}
+
+	for {
+		visited[role.ID] = true
+		for _, permission := range role.Permissions {
+			if !seen[permission.ID] {
+				seen[permission.ID] = true
+				permissions = append(permissions, permission)
+			}
+		}
+
+		if role.ParentID == nil || visited[*role.ParentID] {
+			break
+		}
+
+		parentID := *role.ParentID
+		role = Role{}
+		if err := r.db.Preload("Permissions").Limit(1).Find(&role, parentID).Error; err != nil {
+			return []Permission{}, err
+		}
+		if role.ID == 0 {
+			break
+		}
+	}
+
+	return permissions, nil
+}
+
+func (r *permissionRepository) GrantPermissions(roleID uint, names []string) ([]Permission, error) {
+	return r.changePermissions(roleID, names, func(association *gorm.Association, permissions []Permission) error {
+		return association.Append(&permissions)
+	})
+}
+
+func (r *permissionRepository) RevokePermissions(roleID uint, names []string) ([]Permission, error) {
+	return r.changePermissions(roleID, names, func(association *gorm.Association, permissions []Permission) error {
+		return association.Delete(&permissions)
+	})
+}
+
+// changePermissions locks the role, resolves the named permissions and applies
+// change to the role's permission association.
+func (r *permissionRepository) changePermissions(roleID uint, names []string, change func(*gorm.Association, []Permission) error) ([]Permission, error) {
+
+	var role Role
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&role, roleID).Error; err != nil {
+			return err
+		}
+
+		var permissions []Permission
+		if err := tx.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+			return err
+		}
+
+		if missing := missingPermissions(names, permissions); len(missing) != 0 {
+			return &response.FailedResponseMessage{
+				Message: "Permission not found",
+				Code:    fiber.StatusNotFound,
+				Status:  "failed",
+				Errors:  "unknown permissions: " + strings.Join(missing, ", "),
+			}
+		}
+
+		if err := change(tx.Model(&role).Association("Permissions"), permissions); err != nil {
+			return err
+		}
+
+		return tx.Preload("Permissions").First(&role, roleID).Error
+	})
+
+	if err != nil {
+		return []Permission{}, err
+	}
+
+	return role.Permissions, nil
+}
+
+func missingPermissions(names []string, permissions []Permission) []string {
+	found := map[string]bool{}
+	for _, permission := range permissions {
+		found[permission.Name] = true
+	}
+	var missing []string
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,173 @@
+package role
+
+import (
+	"errors"
+	"go-jwt/common/response"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PermissionService interface {
+	Save(input RegisterInputPermission) (Permission, response.FailedResponseMessage)
+	FindOnePermissionByID(id uint) (Permission, response.FailedResponseMessage)
+	FindPermissionsByCriteria(permission Permission) ([]Permission, response.FailedResponseMessage)
+	UpdateOne(id uint, input UpdateInputPermission) (Permission, response.FailedResponseMessage)
+	SoftDelete(id uint, input SoftDeleteInputPermission) response.FailedResponseMessage
+	FindPermissionsByRoleID(roleID uint) ([]Permission, response.FailedResponseMessage)
+	FindEffectivePermissionsByRoleID(roleID uint) ([]Permission, response.FailedResponseMessage)
+	GrantPermissions(roleID uint, input GrantInputPermission) ([]Permission, response.FailedResponseMessage)
+	RevokePermissions(roleID uint, input GrantInputPermission) ([]Permission, response.FailedResponseMessage)
+}
+
+type permissionService struct {
+	repo  PermissionRepository
+	cache *PermissionCache
+}
+
+func NewPermissionService(repo PermissionRepository, cache *PermissionCache) PermissionService {
+	return &permissionService{repo: repo, cache: cache}
+}
+
+func (s *permissionService) Save(input RegisterInputPermission) (Permission, response.FailedResponseMessage) {
+
+	permission, err := s.repo.Save(Permission{Name: input.Name, Description: input.Description, Version: time.Now().UnixMilli()})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return Permission{}, response.FailedResponseMessage{
+				Message: "Duplicated key for permission " + input.Name,
+				Status:  "failed",
+				Code:    http.StatusBadRequest,
+				Errors:  err.Error(),
+			}
+		}
+		return Permission{}, response.FailedResponseMessage{
+			Message: "Failed to save permission",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	return permission, response.FailedResponseMessage{}
+}
+
+func (s *permissionService) FindOnePermissionByID(id uint) (Permission, response.FailedResponseMessage) {
+	permission, err := s.repo.FindOnePermissionByID(id)
+	if err != nil {
+		return Permission{}, permissionFailedResponse(err, "Failed to get permission")
+	}
+	return permission, response.FailedResponseMessage{}
+}
+
+func (s *permissionService) FindPermissionsByCriteria(permission Permission) ([]Permission, response.FailedResponseMessage) {
+	permissions, err := s.repo.FindPermissionsByCriteria(permission)
+	if err != nil {
+		return []Permission{}, response.FailedResponseMessage{
+			Message: "failed to find permission",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+	return permissions, response.FailedResponseMessage{}
+}
+
+func (s *permissionService) UpdateOne(id uint, input UpdateInputPermission) (Permission, response.FailedResponseMessage) {
+	permission, err := s.repo.FindOneAndLockAndUpdate(id, input)
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return Permission{}, response.FailedResponseMessage{
+				Message: "Duplicated key for permission " + input.Name,
+				Status:  "failed",
+				Code:    http.StatusBadRequest,
+				Errors:  err.Error(),
+			}
+		}
+		return Permission{}, permissionFailedResponse(err, "Failed to update permission")
+	}
+	s.cache.Invalidate()
+	return permission, response.FailedResponseMessage{}
+}
+
+func (s *permissionService) SoftDelete(id uint, input SoftDeleteInputPermission) response.FailedResponseMessage {
+	if err := s.repo.SoftDelete(id, input); err != nil {
+		return permissionFailedResponse(err, "Failed to soft delete permission")
+	}
+	s.cache.Invalidate()
+	return response.FailedResponseMessage{}
+}
+
+func (s *permissionService) FindPermissionsByRoleID(roleID uint) ([]Permission, response.FailedResponseMessage) {
+	permissions, err := s.repo.FindPermissionsByRoleID(roleID)
+	if err != nil {
+		return []Permission{}, roleFailedResponse(err, "Failed to get role permissions")
+	}
+	return permissions, response.FailedResponseMessage{}
+}
+
+func (s *permissionService) FindEffectivePermissionsByRoleID(roleID uint) ([]Permission, response.FailedResponseMessage) {
+	permissions, err := s.repo.FindEffectivePermissionsByRoleID(roleID)
+	if err != nil {
+		return []Permission{}, roleFailedResponse(err, "Failed to get effective role permissions")
+	}
+	return permissions, response.FailedResponseMessage{}
+}
+
+func (s *permissionService) GrantPermissions(roleID uint, input GrantInputPermission) ([]Permission, response.FailedResponseMessage) {
+	permissions, err := s.repo.GrantPermissions(roleID, input.Permissions)
+	if err != nil {
+		return []Permission{}, roleFailedResponse(err, "Failed to grant permissions")
+	}
+	s.cache.Invalidate()
+	return permissions, response.FailedResponseMessage{}
+}
+
+func (s *permissionService) RevokePermissions(roleID uint, input GrantInputPermission) ([]Permission, response.FailedResponseMessage) {
+	permissions, err := s.repo.RevokePermissions(roleID, input.Permissions)
+	if err != nil {
+		return []Permission{}, roleFailedResponse(err, "Failed to revoke permissions")
+	}
+	s.cache.Invalidate()
+	return permissions, response.FailedResponseMessage{}
+}
+
+func permissionFailedResponse(err error, message string) response.FailedResponseMessage {
+	var responseErr *response.FailedResponseMessage
+	if errors.As(err, &responseErr) {
+		return *responseErr
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.FailedResponseMessage{
+			Message: "Permission not found",
+			Status:  "failed",
+			Code:    http.StatusNotFound,
+			Errors:  err.Error(),
+		}
+	}
+	return response.FailedResponseMessage{
+		Message: message,
+		Status:  "failed",
+		Code:    http.StatusInternalServerError,
+		Errors:  err.Error(),
+	}
+}
+
+func roleFailedResponse(err error, message string) response.FailedResponseMessage {
+	var responseErr *response.FailedResponseMessage
+	if errors.As(err, &responseErr) {
+		return *responseErr
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.FailedResponseMessage{
+			Message: "Role not found",
+			Status:  "failed",
+			Code:    http.StatusNotFound,
+			Errors:  err.Error(),
+		}
+	}
+	return response.FailedResponseMessage{
+		Message: message,
+		Status:  "failed",
+		Code:    http.StatusInternalServerError,
+		Errors:  err.Error(),
+	}
+}
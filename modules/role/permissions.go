@@ -0,0 +1,57 @@
+package role
+
+// Permissions guarding the API routes. They are created by the database
+// migration so they can be granted right away.
+const (
+	PermissionRoleCreate      = "role:create"
+	PermissionRoleRead        = "role:read"
+	PermissionRoleUpdate      = "role:update"
+	PermissionRoleDelete      = "role:delete"
+	PermissionRoleGrant       = "role:grant"
+	PermissionPermCreate      = "permission:create"
+	PermissionPermRead        = "permission:read"
+	PermissionPermUpdate      = "permission:update"
+	PermissionPermDelete      = "permission:delete"
+	PermissionUserCreate      = "user:create"
+	PermissionUserRead        = "user:read"
+	PermissionUserUpdate      = "user:update"
+	PermissionUserDelete      = "user:delete"
+	PermissionUserAssign      = "user:assign-role"
+	PermissionUserUnlock      = "user:unlock"
+	PermissionTokenRevoke     = "token:revoke"
+	PermissionTokenIntrospect = "token:introspect"
+	PermissionKeyRead         = "key:read"
+	PermissionKeyRotate       = "key:rotate"
+	PermissionAuthzCheck      = "authz:check"
+	PermissionClientCreate    = "client:create"
+	PermissionClientRead      = "client:read"
+	PermissionClientUpdate    = "client:update"
+	PermissionClientDelete    = "client:delete"
+)
+
+var DefaultPermissions = []Permission{
+	{Name: PermissionRoleCreate, Description: "Create roles"},
+	{Name: PermissionRoleRead, Description: "Read roles"},
+	{Name: PermissionRoleUpdate, Description: "Update and restore roles"},
+	{Name: PermissionRoleDelete, Description: "Delete roles"},
+	{Name: PermissionRoleGrant, Description: "Grant and revoke role permissions"},
+	{Name: PermissionPermCreate, Description: "Create permissions"},
+	{Name: PermissionPermRead, Description: "Read permissions"},
+	{Name: PermissionPermUpdate, Description: "Update permissions"},
+	{Name: PermissionPermDelete, Description: "Delete permissions"},
+	{Name: PermissionUserCreate, Description: "Create users"},
+	{Name: PermissionUserRead, Description: "Read users"},
+	{Name: PermissionUserUpdate, Description: "Update users"},
+	{Name: PermissionUserDelete, Description: "Delete users"},
+	{Name: PermissionUserAssign, Description: "Assign and unassign user roles"},
+	{Name: PermissionUserUnlock, Description: "Clear login lockouts"},
+	{Name: PermissionTokenRevoke, Description: "Revoke the tokens of any user"},
+	{Name: PermissionTokenIntrospect, Description: "Introspect the tokens of any user or client"},
+	{Name: PermissionKeyRead, Description: "List signing keys"},
+	{Name: PermissionKeyRotate, Description: "Rotate signing keys"},
+	{Name: PermissionAuthzCheck, Description: "Ask for policy decisions on behalf of other services"},
+	{Name: PermissionClientCreate, Description: "Register OAuth clients"},
+	{Name: PermissionClientRead, Description: "Read OAuth clients"},
+	{Name: PermissionClientUpdate, Description: "Update OAuth clients and rotate their secrets"},
+	{Name: PermissionClientDelete, Description: "Delete OAuth clients"},
+}
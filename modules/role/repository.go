@@ -1,6 +1,7 @@
 package role
 
 import (
+	"errors"
 	"go-jwt/common/response"
 	"time"
 
@@ -78,12 +79,24 @@ func (r *repository) FindOneAndLockAndUpdate(id uint, input UpdateInputRole) (Ro
 			}
 		}
 
+		parentID := input.ParentID
+		input.ParentID = nil
 		input.Version = time.Now().UnixMilli()
 
 		if err := tx.Model(&role).Updates(input).Error; err != nil {
 			return err
 		}
-		return nil
+
+		if parentID == nil {
+			return nil
+		}
+		if *parentID == 0 {
+			return tx.Model(&role).Update("parent_id", nil).Error
+		}
+		if err := checkParent(tx, role.ID, *parentID); err != nil {
+			return err
+		}
+		return tx.Model(&role).Update("parent_id", *parentID).Error
 	})
 
 	if err != nil {
@@ -93,6 +106,45 @@ func (r *repository) FindOneAndLockAndUpdate(id uint, input UpdateInputRole) (Ro
 	return role, nil
 }
 
+// checkParent walks up from parentID and fails when the chain leads back to
+// roleID, which would make the role inherit from itself.
+func checkParent(tx *gorm.DB, roleID uint, parentID uint) error {
+
+	visited := map[uint]bool{}
+	for id := &parentID; id != nil; {
+		if *id == roleID {
+			return &response.FailedResponseMessage{
+				Message: "Role hierarchy cycle",
+				Code:    fiber.StatusBadRequest,
+				Status:  "failed",
+				Errors:  "A role cannot inherit from itself or from one of the roles that inherit from it.",
+			}
+		}
+		if visited[*id] {
+			return nil
+		}
+		visited[*id] = true
+
+		var parent Role
+		if err := tx.Select("id", "parent_id").First(&parent, *id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) && *id == parentID {
+				return &response.FailedResponseMessage{
+					Message: "Parent role not found",
+					Code:    fiber.StatusBadRequest,
+					Status:  "failed",
+					Errors:  err.Error(),
+				}
+			} else if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		id = parent.ParentID
+	}
+
+	return nil
+}
+
 func (r *repository) FindRolesByCrtieria(role Role) ([]Role, error) {
 	var result []Role
 	if err := r.db.Where(&role).Find(&result).Error; err != nil {
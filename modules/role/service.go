@@ -20,16 +20,39 @@ type Service interface {
 }
 
 type service struct {
-	repo Repository
+	repo  Repository
+	cache *PermissionCache
 }
 
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+func NewService(repo Repository, cache *PermissionCache) Service {
+	return &service{repo: repo, cache: cache}
 }
 
 func (s *service) Save(input RegisterInputRole) (Role, response.FailedResponseMessage) {
 
-	save, err := s.repo.Save(Role{Name: input.Name, Version: time.Now().UnixMilli()})
+	if input.ParentID != nil && *input.ParentID == 0 {
+		input.ParentID = nil
+	}
+	if input.ParentID != nil {
+		if _, err := s.repo.FindOneRoleByID(*input.ParentID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return Role{}, response.FailedResponseMessage{
+					Message: "Parent role not found",
+					Status:  "failed",
+					Code:    http.StatusBadRequest,
+					Errors:  err.Error(),
+				}
+			}
+			return Role{}, response.FailedResponseMessage{
+				Message: "Failed to get parent role",
+				Status:  "failed",
+				Code:    http.StatusInternalServerError,
+				Errors:  err.Error(),
+			}
+		}
+	}
+
+	save, err := s.repo.Save(Role{Name: input.Name, ParentID: input.ParentID, Version: time.Now().UnixMilli()})
 	if err != nil {
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			return Role{}, response.FailedResponseMessage{
@@ -76,7 +99,17 @@ func (s *service) FindOneRoleByName(name string) (Role, response.FailedResponseM
 func (s *service) UpdateOne(id uint, input UpdateInputRole) (Role, response.FailedResponseMessage) {
 	role, err := s.repo.FindOneAndLockAndUpdate(id, input)
 	if err != nil {
-		if errors.Is(err, gorm.ErrDuplicatedKey) {
+		var responseErr *response.FailedResponseMessage
+		if errors.As(err, &responseErr) {
+			return Role{}, *responseErr
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Role{}, response.FailedResponseMessage{
+				Message: "Role not found",
+				Status:  "failed",
+				Code:    http.StatusNotFound,
+				Errors:  err.Error(),
+			}
+		} else if errors.Is(err, gorm.ErrDuplicatedKey) {
 			return Role{}, response.FailedResponseMessage{
 				Message: "Duplicated key for role " + input.Name,
 				Status:  "failed",
@@ -91,6 +124,7 @@ func (s *service) UpdateOne(id uint, input UpdateInputRole) (Role, response.Fail
 			Errors:  err.Error(),
 		}
 	}
+	s.cache.Invalidate()
 	return role, response.FailedResponseMessage{}
 }
 
@@ -162,6 +196,7 @@ func (s *service) SoftDelete(id uint, input SoftDeleteInputRole) response.Failed
 		}
 	}
 
+	s.cache.Invalidate()
 	return response.FailedResponseMessage{}
 }
 
@@ -191,5 +226,6 @@ func (s *service) RestoreDataSoftDelete(name string) (Role, response.FailedRespo
 
 	}
 
+	s.cache.Invalidate()
 	return role, response.FailedResponseMessage{}
 }
@@ -1,9 +1,12 @@
 package user
 
 import (
+	"go-jwt/common/policy"
 	"go-jwt/common/response"
+	"go-jwt/modules/role"
 	"net/http"
 	"reflect"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -39,6 +42,11 @@ func (h *handler) Create(c *fiber.Ctx) error {
 		}
 	}
 
+	resource := policy.Resource{Type: "user", Attributes: policy.Attributes{"username": input.Username, "department": input.Department}}
+	if err := policy.Authorize(c, role.PermissionUserCreate, resource); err != nil {
+		return err
+	}
+
 	user, err := h.userService.Save(input)
 	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
 		return &err
@@ -77,6 +85,30 @@ func (h *handler) SoftDelete(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully deleted user", http.StatusOK, nil))
 }
 
+func (h *handler) Update(c *fiber.Ctx) error {
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	var input UpdateInputUser
+	if errParse := parseAndValidate(c, &input); errParse != nil {
+		return errParse
+	}
+
+	if err := h.authorize(c, role.PermissionUserUpdate, id, nil); err != nil {
+		return err
+	}
+
+	user, err := h.userService.Update(id, input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully updated user", http.StatusOK, user))
+}
+
 func (h *handler) FindOneByUsername(c *fiber.Ctx) error {
 	username := c.Params("username")
 
@@ -87,3 +119,125 @@ func (h *handler) FindOneByUsername(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find user", http.StatusOK, user))
 }
+
+func (h *handler) FindRoles(c *fiber.Ctx) error {
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	roles, err := h.userService.FindRolesByUserID(id)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully find user roles", http.StatusOK, roles))
+}
+
+func (h *handler) AssignRoles(c *fiber.Ctx) error {
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	var input AssignInputRole
+	if errParse := parseAndValidate(c, &input); errParse != nil {
+		return errParse
+	}
+
+	if err := h.authorize(c, role.PermissionUserAssign, id, policy.Attributes{"assigned_roles": input.Roles}); err != nil {
+		return err
+	}
+
+	roles, err := h.userService.AssignRoles(id, input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully assigned roles", http.StatusOK, roles))
+}
+
+func (h *handler) UnassignRoles(c *fiber.Ctx) error {
+
+	id, errParse := parseID(c)
+	if errParse != nil {
+		return errParse
+	}
+
+	var input AssignInputRole
+	if errParse := parseAndValidate(c, &input); errParse != nil {
+		return errParse
+	}
+
+	if err := h.authorize(c, role.PermissionUserAssign, id, policy.Attributes{"unassigned_roles": input.Roles}); err != nil {
+		return err
+	}
+
+	roles, err := h.userService.UnassignRoles(id, input)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.BuildSuccessResponseMessage("successfully unassigned roles", http.StatusOK, roles))
+}
+
+// authorize asks the policy engine whether the caller may perform action on
+// the user. extra is merged into the user's attributes.
+func (h *handler) authorize(c *fiber.Ctx, action string, id uint, extra policy.Attributes) error {
+
+	user, err := h.userService.FindOneUserByID(id)
+	if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return &err
+	}
+
+	var roles []string
+	for _, role := range user.Roles {
+		roles = append(roles, role.Name)
+	}
+
+	attributes := policy.Attributes{"id": user.ID, "username": user.Username, "department": user.Department, "roles": roles}
+	for name, value := range extra {
+		attributes[name] = value
+	}
+
+	return policy.Authorize(c, action, policy.Resource{Type: "user", Attributes: attributes})
+}
+
+func parseAndValidate(c *fiber.Ctx, input interface{}) error {
+
+	if err := c.BodyParser(input); err != nil {
+		return &response.FailedResponseMessage{
+			Message: "Failed to parse request body",
+			Status:  "failed",
+			Code:    fiber.StatusUnprocessableEntity,
+			Errors:  err.Error(),
+		}
+	}
+
+	validation := response.ValidateBodyRequest(input)
+	if len(validation) != 0 {
+		return &response.FailedResponseMessage{
+			Message: "Failed request body",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  validation,
+		}
+	}
+
+	return nil
+}
+
+func parseID(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return 0, &response.FailedResponseMessage{
+			Message: "Invalid Convert ID",
+			Status:  "failed",
+			Code:    fiber.StatusBadRequest,
+			Errors:  err.Error(),
+		}
+	}
+	return uint(id), nil
+}
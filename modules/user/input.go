@@ -2,9 +2,11 @@ package user
 
 type (
 	RegisterInputUser struct {
-		Username string `json:"username" validate:"required"`
-		Password string `json:"password" validate:"required"`
-		RoleID   uint   `json:"role_id" validate:"required"`
+		Username   string `json:"username" validate:"required"`
+		Email      string `json:"email" validate:"omitempty,email"`
+		Password   string `json:"password" validate:"required"`
+		Department string `json:"department"`
+		RoleIDs    []uint `json:"role_ids" validate:"required,min=1"`
 	}
 
 	SoftDeleteInputUser struct {
@@ -13,9 +15,14 @@ type (
 	}
 
 	UpdateInputUser struct {
-		Username string `json:"username"`
-		Password string `json:"password" `
-		RoleID   uint   `json:"role_id"`
-		Version  int64  `json:"version" validate:"required"`
+		Username   string `json:"username"`
+		Email      string `json:"email" validate:"omitempty,email"`
+		Password   string `json:"password"`
+		Department string `json:"department"`
+		Version    int64  `json:"version" validate:"required"`
+	}
+
+	AssignInputRole struct {
+		Roles []string `json:"roles" validate:"required,min=1,dive,required"`
 	}
 )
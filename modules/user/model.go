@@ -1,18 +1,31 @@
 package user
 
 import (
+	"go-jwt/modules/role"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type User struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
-	Username  string         `gorm:"not null;unique" json:"username"`
-	Password  string         `gorm:"not null" json:"password"`
-	RoleID    uint           `gorm:"not null" json:"role_id"`
-	Version   int64          `gorm:"not null" json:"version"`
+	ID              uint           `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	Username        string         `gorm:"not null;unique" json:"username"`
+	Email           string         `gorm:"uniqueIndex:idx_users_email_unique,where:email <> ''" json:"email"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	Password        string         `gorm:"not null" json:"-"`
+	Department      string         `json:"department"`
+	Version         int64          `gorm:"not null" json:"version"`
+	Roles           []role.Role    `gorm:"many2many:user_roles" json:"roles,omitempty"`
+}
+
+// PasswordHistory keeps the hashes of the passwords a user has had, so the
+// password policy can refuse reusing them.
+type PasswordHistory struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Hash      string    `gorm:"not null" json:"-"`
 }
@@ -3,6 +3,7 @@ package user
 import (
 	"go-jwt/common/response"
 	"go-jwt/modules/role"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,10 +14,17 @@ import (
 type Repository interface {
 	Save(user User) (User, error)
 	FindUserOneUserByUsername(username string) (User, error)
+	FindOneUserByID(id uint) (User, error)
 	FindUsersByCriteria(user User) ([]User, error)
 	SoftDelete(id uint, version int64) error
-	UpdateOne(id uint, user UpdateInputUser) (User, error)
-	FindOneRoleByUsername(username string) (role.Role, error)
+	UpdateOne(id uint, user UpdateInputUser, passwordHistory int) (User, error)
+	UpdatePassword(id uint, passwordHash string, passwordHistory int) (User, error)
+	RehashPassword(id uint, oldHash, newHash string) error
+	VerifyEmail(id uint, email string) (bool, error)
+	FindPasswordHistory(id uint, limit int) ([]string, error)
+	FindRolesByUserID(id uint) ([]role.Role, error)
+	AssignRoles(id uint, names []string) ([]role.Role, error)
+	UnassignRoles(id uint, names []string) ([]role.Role, error)
 }
 
 type repository struct {
@@ -25,29 +33,33 @@ type repository struct {
 
 // Save implements Repository.
 func (r *repository) Save(user User) (User, error) {
-	result := r.db.Save(&user).Error
+	result := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&PasswordHistory{UserID: user.ID, Hash: user.Password}).Error
+	})
 	if result != nil {
 		return user, result
 	}
 	return user, nil
 }
 
-// FindOneRoleByUsername implements Repository.
-func (r *repository) FindOneRoleByUsername(username string) (role.Role, error) {
-	var role role.Role
-	if err := r.db.Where(User{Username: username}).Preload(clause.Associations).First(&role).Error; err != nil {
-		return role, err
-	}
-	return role, nil
-}
-
 func NewRepository(db *gorm.DB) Repository {
 	return &repository{db: db}
 }
 
 func (r *repository) FindUserOneUserByUsername(username string) (User, error) {
 	var user User
-	if err := r.db.Where(User{Username: username}).First(&user).Error; err != nil {
+	if err := r.db.Where(User{Username: username}).Preload("Roles").First(&user).Error; err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+func (r *repository) FindOneUserByID(id uint) (User, error) {
+	var user User
+	if err := r.db.Preload("Roles").First(&user, id).Error; err != nil {
 		return user, err
 	}
 	return user, nil
@@ -92,18 +104,16 @@ func (r *repository) SoftDelete(id uint, version int64) error {
 	return nil
 }
 
-func (r *repository) UpdateOne(id uint, input UpdateInputUser) (User, error) {
+// UpdateOne applies the non-zero fields of input. A new password must already
+// be hashed; it is added to the history, which is trimmed to the last
+// passwordHistory entries.
+func (r *repository) UpdateOne(id uint, input UpdateInputUser, passwordHistory int) (User, error) {
 
 	var user User
 
 	if err := r.db.Transaction(func(tx *gorm.DB) error {
 
-		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Where(&user, id).First(&user).Error; err != nil {
-			return err
-		}
-
-		var role role.Role
-		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&role, input.RoleID).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&user, id).Error; err != nil {
 			return err
 		}
 
@@ -118,19 +128,178 @@ func (r *repository) UpdateOne(id uint, input UpdateInputUser) (User, error) {
 
 		input.Version = time.Now().UnixMilli()
 
-		result := r.db.Model(&user).Updates(input)
-		if result.Error != nil {
-			return result.Error
+		if input.Email != "" && input.Email != user.Email {
+			if err := tx.Model(&user).Update("email_verified_at", nil).Error; err != nil {
+				return err
+			}
 		}
 
-		if result.RowsAffected > 0 {
-			return gorm.ErrRecordNotFound
+		if err := tx.Model(&user).Updates(User{
+			Username:   input.Username,
+			Email:      input.Email,
+			Password:   input.Password,
+			Department: input.Department,
+			Version:    input.Version,
+		}).Error; err != nil {
+			return err
 		}
 
-		return nil
+		if input.Password != "" {
+			if err := recordPassword(tx, user.ID, input.Password, passwordHistory); err != nil {
+				return err
+			}
+		}
+
+		return tx.Preload("Roles").First(&user, id).Error
+	}); err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// UpdatePassword sets a new password hash without a version check, for
+// changes made by the user rather than through the user API.
+func (r *repository) UpdatePassword(id uint, passwordHash string, passwordHistory int) (User, error) {
+
+	var user User
+
+	if err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&user, id).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&user).Updates(User{Password: passwordHash, Version: time.Now().UnixMilli()}).Error; err != nil {
+			return err
+		}
+
+		return recordPassword(tx, user.ID, passwordHash, passwordHistory)
 	}); err != nil {
-		return user, nil
+		return User{}, err
 	}
 
 	return user, nil
 }
+
+// RehashPassword replaces the hash of an unchanged password by one with
+// stronger parameters. It does nothing when the password has been changed
+// since oldHash was read. The version is left alone because the user has
+// not changed.
+func (r *repository) RehashPassword(id uint, oldHash, newHash string) error {
+	return r.db.Model(&User{}).Where("id = ? AND password = ?", id, oldHash).UpdateColumn("password", newHash).Error
+}
+
+// VerifyEmail marks email as verified for the user, and reports false when it
+// is no longer the user's address or was verified already.
+func (r *repository) VerifyEmail(id uint, email string) (bool, error) {
+	result := r.db.Model(&User{}).Where("id = ? AND email = ? AND email_verified_at IS NULL", id, email).UpdateColumn("email_verified_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// FindPasswordHistory returns the last limit password hashes of the user,
+// newest first.
+func (r *repository) FindPasswordHistory(id uint, limit int) ([]string, error) {
+	var hashes []string
+	if err := r.db.Model(&PasswordHistory{}).Where(&PasswordHistory{UserID: id}).Order("id DESC").Limit(limit).Pluck("hash", &hashes).Error; err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// recordPassword adds hash to the history of the user and drops the entries
+// beyond the newest keep.
+func recordPassword(tx *gorm.DB, userID uint, hash string, keep int) error {
+
+	if err := tx.Create(&PasswordHistory{UserID: userID, Hash: hash}).Error; err != nil {
+		return err
+	}
+
+	var kept []uint
+	if err := tx.Model(&PasswordHistory{}).Where(&PasswordHistory{UserID: userID}).Order("id DESC").Limit(keep).Pluck("id", &kept).Error; err != nil {
+		return err
+	}
+	if len(kept) == 0 {
+		return tx.Where(&PasswordHistory{UserID: userID}).Delete(&PasswordHistory{}).Error
+	}
+	return tx.Where("user_id = ? AND id NOT IN ?", userID, kept).Delete(&PasswordHistory{}).Error
+}
+
+func (r *repository) FindRolesByUserID(id uint) ([]role.Role, error) {
+
+	var user User
+	if err := r.db.Preload("Roles").First(&user, id).Error; err != nil {
+		return []role.Role{}, err
+	}
+
+	return user.Roles, nil
+}
+
+func (r *repository) AssignRoles(id uint, names []string) ([]role.Role, error) {
+	return r.changeRoles(id, names, func(association *gorm.Association, roles []role.Role) error {
+		return association.Append(&roles)
+	})
+}
+
+func (r *repository) UnassignRoles(id uint, names []string) ([]role.Role, error) {
+	return r.changeRoles(id, names, func(association *gorm.Association, roles []role.Role) error {
+		return association.Delete(&roles)
+	})
+}
+
+// changeRoles locks the user, resolves the named roles and applies change to
+// the user's role association.
+func (r *repository) changeRoles(id uint, names []string, change func(*gorm.Association, []role.Role) error) ([]role.Role, error) {
+
+	var user User
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := tx.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).First(&user, id).Error; err != nil {
+			return err
+		}
+
+		var roles []role.Role
+		if err := tx.Where("name IN ?", names).Find(&roles).Error; err != nil {
+			return err
+		}
+
+		if missing := missingRoles(names, roles); len(missing) != 0 {
+			return &response.FailedResponseMessage{
+				Message: "Role not found",
+				Code:    fiber.StatusNotFound,
+				Status:  "failed",
+				Errors:  "unknown roles: " + strings.Join(missing, ", "),
+			}
+		}
+
+		if err := change(tx.Model(&user).Association("Roles"), roles); err != nil {
+			return err
+		}
+
+		return tx.Preload("Roles").First(&user, id).Error
+	})
+
+	if err != nil {
+		return []role.Role{}, err
+	}
+
+	return user.Roles, nil
+}
+
+func missingRoles(names []string, roles []role.Role) []string {
+	found := map[string]bool{}
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+	var missing []string
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -2,21 +2,29 @@ package user
 
 import (
 	"errors"
+	"fmt"
+	"go-jwt/common/password"
 	"go-jwt/common/response"
 	"go-jwt/modules/role"
 	"net/http"
+	"reflect"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type Service interface {
 	Save(input RegisterInputUser) (User, response.FailedResponseMessage)
 	FindOneUserByUsername(username string) (User, response.FailedResponseMessage)
+	FindOneUserByID(id uint) (User, response.FailedResponseMessage)
 	FindUsersByCriteria(user User) ([]User, response.FailedResponseMessage)
 	SoftDelete(id uint, version int64) response.FailedResponseMessage
 	Update(id uint, input UpdateInputUser) (User, response.FailedResponseMessage)
+	ChangePassword(id uint, newPassword string) (User, response.FailedResponseMessage)
+	CheckPassword(id uint, newPassword string) response.FailedResponseMessage
+	FindRolesByUserID(id uint) ([]role.Role, response.FailedResponseMessage)
+	AssignRoles(id uint, input AssignInputRole) ([]role.Role, response.FailedResponseMessage)
+	UnassignRoles(id uint, input AssignInputRole) ([]role.Role, response.FailedResponseMessage)
 }
 
 type service struct {
@@ -30,56 +38,52 @@ func NewService(userRepo Repository, roleRepo role.Repository) Service {
 
 func (s *service) Save(input RegisterInputUser) (User, response.FailedResponseMessage) {
 
-	role, err := s.roleRepo.FindOneRoleByID(input.RoleID)
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return User{}, response.FailedResponseMessage{
-				Message: "role not found",
-				Status:  "failed",
-				Code:    http.StatusBadRequest,
-				Errors:  err.Error(),
-			}
-		} else {
-			return User{}, response.FailedResponseMessage{
-				Message: "failed to find role by id for check role is empty or not empty",
-				Status:  "failed",
-				Code:    http.StatusInternalServerError,
-				Errors:  err.Error(),
+	var roles []role.Role
+	for _, roleID := range input.RoleIDs {
+		role, err := s.roleRepo.FindOneRoleByID(roleID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return User{}, response.FailedResponseMessage{
+					Message: "role not found",
+					Status:  "failed",
+					Code:    http.StatusBadRequest,
+					Errors:  err.Error(),
+				}
+			} else {
+				return User{}, response.FailedResponseMessage{
+					Message: "failed to find role by id for check role is empty or not empty",
+					Status:  "failed",
+					Code:    http.StatusInternalServerError,
+					Errors:  err.Error(),
+				}
 			}
 		}
+		roles = append(roles, role)
 	}
 
-	if role.ID != input.RoleID {
-		return User{}, response.FailedResponseMessage{
-			Message: "role not found",
-			Status:  "failed",
-			Code:    http.StatusBadRequest,
-			Errors:  "role not found",
-		}
+	if err := s.checkPassword(User{Username: input.Username}, input.Password); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return User{}, err
 	}
 
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return User{}, response.FailedResponseMessage{
-			Message: "Failed to hash password",
-			Status:  "failed",
-			Errors:  err.Error(),
-			Code:    http.StatusInternalServerError,
-		}
+	passwordHash, errHash := hashPassword(input.Password)
+	if !reflect.DeepEqual(errHash, response.FailedResponseMessage{}) {
+		return User{}, errHash
 	}
 
 	var toSaveUser = User{
-		Username: input.Username,
-		RoleID:   role.ID,
-		Password: string(passwordHash),
-		Version:  time.Now().UnixMilli(),
+		Username:   input.Username,
+		Email:      input.Email,
+		Department: input.Department,
+		Roles:      roles,
+		Password:   passwordHash,
+		Version:    time.Now().UnixMilli(),
 	}
 
 	user, err := s.userRepo.Save(toSaveUser)
 	if err != nil {
 		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			return User{}, response.FailedResponseMessage{
-				Message: "Duplicated key for username " + input.Username,
+				Message: duplicatedUserMessage(input.Username, input.Email),
 				Status:  "failed",
 				Errors:  err.Error(),
 				Code:    http.StatusBadRequest,
@@ -124,6 +128,14 @@ func (s *service) FindOneUserByUsername(username string) (User, response.FailedR
 	return user, response.FailedResponseMessage{}
 }
 
+func (s *service) FindOneUserByID(id uint) (User, response.FailedResponseMessage) {
+	user, err := s.userRepo.FindOneUserByID(id)
+	if err != nil {
+		return User{}, userFailedResponse(err, "Failed to find user by id")
+	}
+	return user, response.FailedResponseMessage{}
+}
+
 // FindUsersByCriteria implements Service.
 func (s *service) FindUsersByCriteria(user User) ([]User, response.FailedResponseMessage) {
 
@@ -166,7 +178,26 @@ func (s *service) SoftDelete(id uint, version int64) response.FailedResponseMess
 
 func (s *service) Update(id uint, input UpdateInputUser) (User, response.FailedResponseMessage) {
 
-	user, err := s.userRepo.UpdateOne(id, input)
+	if input.Password != "" {
+		existing, err := s.FindOneUserByID(id)
+		if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+			return User{}, err
+		}
+		if input.Username != "" {
+			existing.Username = input.Username
+		}
+		if err := s.checkPassword(existing, input.Password); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+			return User{}, err
+		}
+
+		passwordHash, err := hashPassword(input.Password)
+		if !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+			return User{}, err
+		}
+		input.Password = passwordHash
+	}
+
+	user, err := s.userRepo.UpdateOne(id, input, password.Current().History)
 	if err != nil {
 		var responseFailed *response.FailedResponseMessage
 		if errors.As(err, &responseFailed) {
@@ -178,6 +209,13 @@ func (s *service) Update(id uint, input UpdateInputUser) (User, response.FailedR
 				Code:    http.StatusNotFound,
 				Errors:  err.Error(),
 			}
+		} else if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return User{}, response.FailedResponseMessage{
+				Message: duplicatedUserMessage(input.Username, input.Email),
+				Status:  "failed",
+				Code:    http.StatusBadRequest,
+				Errors:  err.Error(),
+			}
 		} else {
 			return User{}, response.FailedResponseMessage{
 				Message: "Failed to update user",
@@ -190,3 +228,152 @@ func (s *service) Update(id uint, input UpdateInputUser) (User, response.FailedR
 	}
 	return user, response.FailedResponseMessage{}
 }
+
+// ChangePassword applies the password policy to newPassword and makes it the
+// password of the user.
+func (s *service) ChangePassword(id uint, newPassword string) (User, response.FailedResponseMessage) {
+
+	user, errFind := s.FindOneUserByID(id)
+	if !reflect.DeepEqual(errFind, response.FailedResponseMessage{}) {
+		return User{}, errFind
+	}
+
+	if err := s.checkPassword(user, newPassword); !reflect.DeepEqual(err, response.FailedResponseMessage{}) {
+		return User{}, err
+	}
+
+	passwordHash, errHash := hashPassword(newPassword)
+	if !reflect.DeepEqual(errHash, response.FailedResponseMessage{}) {
+		return User{}, errHash
+	}
+
+	user, err := s.userRepo.UpdatePassword(id, passwordHash, password.Current().History)
+	if err != nil {
+		return User{}, userFailedResponse(err, "Failed to change password")
+	}
+	return user, response.FailedResponseMessage{}
+}
+
+// CheckPassword reports whether ChangePassword would accept newPassword for
+// the user, without changing anything.
+func (s *service) CheckPassword(id uint, newPassword string) response.FailedResponseMessage {
+
+	user, errFind := s.FindOneUserByID(id)
+	if !reflect.DeepEqual(errFind, response.FailedResponseMessage{}) {
+		return errFind
+	}
+	return s.checkPassword(user, newPassword)
+}
+
+func (s *service) FindRolesByUserID(id uint) ([]role.Role, response.FailedResponseMessage) {
+	roles, err := s.userRepo.FindRolesByUserID(id)
+	if err != nil {
+		return []role.Role{}, userFailedResponse(err, "Failed to get user roles")
+	}
+	return roles, response.FailedResponseMessage{}
+}
+
+func (s *service) AssignRoles(id uint, input AssignInputRole) ([]role.Role, response.FailedResponseMessage) {
+	roles, err := s.userRepo.AssignRoles(id, input.Roles)
+	if err != nil {
+		return []role.Role{}, userFailedResponse(err, "Failed to assign roles")
+	}
+	return roles, response.FailedResponseMessage{}
+}
+
+func (s *service) UnassignRoles(id uint, input AssignInputRole) ([]role.Role, response.FailedResponseMessage) {
+	roles, err := s.userRepo.UnassignRoles(id, input.Roles)
+	if err != nil {
+		return []role.Role{}, userFailedResponse(err, "Failed to unassign roles")
+	}
+	return roles, response.FailedResponseMessage{}
+}
+
+// checkPassword applies the password policy to a new password of user. For an
+// existing user it also refuses the current and the recently used passwords.
+func (s *service) checkPassword(user User, newPassword string) response.FailedResponseMessage {
+
+	violations, err := password.Validate(newPassword, user.Username)
+	if err != nil {
+		return response.FailedResponseMessage{
+			Message: "Failed to check password",
+			Status:  "failed",
+			Code:    http.StatusInternalServerError,
+			Errors:  err.Error(),
+		}
+	}
+
+	if history := password.Current().History; user.ID != 0 && history > 0 {
+		hashes, err := s.userRepo.FindPasswordHistory(user.ID, history)
+		if err != nil {
+			return response.FailedResponseMessage{
+				Message: "Failed to check password",
+				Status:  "failed",
+				Code:    http.StatusInternalServerError,
+				Errors:  err.Error(),
+			}
+		}
+		for _, hash := range append(hashes, user.Password) {
+			if match, _, _ := password.Verify(hash, newPassword); match {
+				violations = append(violations, fmt.Sprintf("password must differ from the last %d passwords", history))
+				break
+			}
+		}
+	}
+
+	if len(violations) != 0 {
+		return response.FailedResponseMessage{
+			Message: "Password does not meet the password policy",
+			Status:  "failed",
+			Code:    http.StatusBadRequest,
+			Errors:  violations,
+		}
+	}
+	return response.FailedResponseMessage{}
+}
+
+// duplicatedUserMessage names the unique fields a save could have clashed on.
+// Usernames and email addresses both belong to one user only.
+func duplicatedUserMessage(username, email string) string {
+	switch {
+	case username != "" && email != "":
+		return "Duplicated key for username " + username + " or email " + email
+	case email != "":
+		return "Duplicated key for email " + email
+	default:
+		return "Duplicated key for username " + username
+	}
+}
+
+func hashPassword(plain string) (string, response.FailedResponseMessage) {
+	hash, err := password.Hash(plain)
+	if err != nil {
+		return "", response.FailedResponseMessage{
+			Message: "Failed to hash password",
+			Status:  "failed",
+			Errors:  err.Error(),
+			Code:    http.StatusInternalServerError,
+		}
+	}
+	return hash, response.FailedResponseMessage{}
+}
+
+func userFailedResponse(err error, message string) response.FailedResponseMessage {
+	var responseErr *response.FailedResponseMessage
+	if errors.As(err, &responseErr) {
+		return *responseErr
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.FailedResponseMessage{
+			Message: "User not found",
+			Status:  "failed",
+			Code:    http.StatusNotFound,
+			Errors:  err.Error(),
+		}
+	}
+	return response.FailedResponseMessage{
+		Message: message,
+		Status:  "failed",
+		Code:    http.StatusInternalServerError,
+		Errors:  err.Error(),
+	}
+}